@@ -0,0 +1,850 @@
+package gokql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// NodeKind identifies the type of a node of the syntax tree.
+type NodeKind int
+
+const (
+	// KindOr is a disjunction of expressions: `a:1 or b:2`.
+	KindOr NodeKind = iota
+	// KindAnd is a conjunction of expressions: `a:1 and b:2`.
+	KindAnd
+	// KindNot is a negated expression: `not a:1`.
+	KindNot
+	// KindMatch compares a property with a value or a value list: `a:1`, `a>=1`, `a:(1 or 2)`.
+	KindMatch
+	// KindNested matches a nested object or every object of a slice: `a:{b:1}`.
+	KindNested
+	// KindTerm is a field-less term: `error`.
+	KindTerm
+	// KindValue is a single value.
+	KindValue
+	// KindValueOr is a value list matching any of its values: `(1 or 2)`.
+	KindValueOr
+	// KindValueAnd is a value list matching all of its values: `(1 and 2)`.
+	KindValueAnd
+	// KindValueNot is a negated value inside a value list: `(1 or not 2)`.
+	KindValueNot
+)
+
+func (kind NodeKind) String() string {
+	switch kind {
+	case KindOr:
+		return "or"
+	case KindAnd:
+		return "and"
+	case KindNot:
+		return "not"
+	case KindMatch:
+		return "match"
+	case KindNested:
+		return "nested"
+	case KindTerm:
+		return "term"
+	case KindValue:
+		return "value"
+	case KindValueOr:
+		return "value or"
+	case KindValueAnd:
+		return "value and"
+	case KindValueNot:
+		return "value not"
+	}
+	return fmt.Sprintf("NodeKind(%d)", int(kind))
+}
+
+// Node is a node of the syntax tree of an expression.
+//
+// A tree returned by Expression.Root is a copy: modifying it does not affect the expression.
+// Use Rewrite or NewExpression to get an expression from a modified tree.
+type Node interface {
+	Kind() NodeKind
+	String() string
+}
+
+// OrNode matches if any of its children matches.
+type OrNode struct {
+	Children []Node
+}
+
+// AndNode matches if all of its children match.
+type AndNode struct {
+	Children []Node
+}
+
+// NotNode inverts the result of its child.
+type NotNode struct {
+	Child Node
+}
+
+// MatchNode compares the property at Field with Value using Operator,
+// which is one of ":", "<", "<=", ">", ">=".
+// Value is a *ValueNode or a value list: a *ValueOrNode, *ValueAndNode or *ValueNotNode.
+// Every value of a list is compared with the property using Operator.
+//
+// If FieldPattern is set, the parts of Field are in pattern form, like the text of an unquoted
+// ValueNode, and the node matches if any property whose dotted path matches the pattern matches.
+type MatchNode struct {
+	Field        []string
+	FieldPattern bool
+	Operator     string
+	Value        Node
+}
+
+// NestedNode matches Query against the object at Field, or against every object if Field is a slice.
+// Field names inside Query are relative to Field.
+// FieldPattern has the same meaning as in MatchNode.
+type NestedNode struct {
+	Field        []string
+	FieldPattern bool
+	Query        Node
+}
+
+// TermNode is a field-less term matched against the default fields of an expression.
+type TermNode struct {
+	Value *ValueNode
+}
+
+// ValueNode is a value of a query. Text of an unquoted value can contain `*` wildcards,
+// and `\*` and `\\` stand for a literal star and backslash in it. Text of a quoted value
+// is matched literally.
+type ValueNode struct {
+	Text string
+	// Quoted reports whether the value was written in quotes. Quoted values
+	// of text fields are matched as phrases (see FieldText).
+	Quoted bool
+	// Regexp reports whether the value is a regular expression such as `/ab+c/`.
+	// Text then holds the pattern without the enclosing slashes.
+	Regexp bool
+}
+
+// ValueOrNode is a value list which matches if any of its values matches.
+type ValueOrNode struct {
+	Values []Node
+}
+
+// ValueAndNode is a value list which matches if all of its values match.
+type ValueAndNode struct {
+	Values []Node
+}
+
+// ValueNotNode inverts the result of a value or a value list.
+type ValueNotNode struct {
+	Value Node
+}
+
+func (*OrNode) Kind() NodeKind       { return KindOr }
+func (*AndNode) Kind() NodeKind      { return KindAnd }
+func (*NotNode) Kind() NodeKind      { return KindNot }
+func (*MatchNode) Kind() NodeKind    { return KindMatch }
+func (*NestedNode) Kind() NodeKind   { return KindNested }
+func (*TermNode) Kind() NodeKind     { return KindTerm }
+func (*ValueNode) Kind() NodeKind    { return KindValue }
+func (*ValueOrNode) Kind() NodeKind  { return KindValueOr }
+func (*ValueAndNode) Kind() NodeKind { return KindValueAnd }
+func (*ValueNotNode) Kind() NodeKind { return KindValueNot }
+
+func (n *OrNode) String() string {
+	return joinNodes(n.Children, " or ")
+}
+
+func (n *AndNode) String() string {
+	return joinNodes(n.Children, " and ")
+}
+
+func (n *NotNode) String() string {
+	return "not " + nodeString(n.Child)
+}
+
+func (n *MatchNode) String() string {
+	value := nodeString(n.Value)
+	if _, ok := n.Value.(*ValueNode); !ok && !strings.HasPrefix(value, "(") {
+		value = "(" + value + ")"
+	}
+	return nodeFieldName(n.Field, n.FieldPattern) + n.Operator + value
+}
+
+func (n *NestedNode) String() string {
+	return nodeFieldName(n.Field, n.FieldPattern) + ":{" + nodeString(n.Query) + "}"
+}
+
+func nodeFieldName(field []string, pattern bool) string {
+	if pattern {
+		return formatFieldPattern(field)
+	}
+	return formatFieldName(field)
+}
+
+func (n *TermNode) String() string {
+	return nodeString(n.Value)
+}
+
+func (n *ValueNode) String() string {
+	if n.Quoted {
+		return quoteValue(n.Text)
+	}
+	if n.Regexp {
+		return formatRegexp(n.Text)
+	}
+	return formatPattern(n.Text)
+}
+
+func (n *ValueOrNode) String() string {
+	return joinNodes(n.Values, " or ")
+}
+
+func (n *ValueAndNode) String() string {
+	return joinNodes(n.Values, " and ")
+}
+
+func (n *ValueNotNode) String() string {
+	return "not " + nodeString(n.Value)
+}
+
+func nodeString(node Node) string {
+	if node == nil {
+		return ""
+	}
+	if value, ok := node.(*ValueNode); ok && value == nil {
+		return ""
+	}
+	return node.String()
+}
+
+func joinNodes(nodes []Node, separator string) string {
+	if len(nodes) == 1 {
+		return nodeString(nodes[0])
+	}
+
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		parts[i] = nodeString(node)
+	}
+	return "(" + strings.Join(parts, separator) + ")"
+}
+
+// Root returns a copy of the syntax tree of the expression.
+func (expression Expression) Root() Node {
+	if expression.ast == nil {
+		return nil
+	}
+	return expression.ast.node()
+}
+
+// Fields returns dotted names of all properties the expression refers to, in order of appearance.
+// Names of properties inside nested queries include the name of the nested property.
+func (expression Expression) Fields() []string {
+	var fields []string
+	seen := map[string]bool{}
+	var collect func(node Node, prefix []string)
+	collect = func(node Node, prefix []string) {
+		Walk(node, func(node Node) bool {
+			var field []string
+			switch n := node.(type) {
+			case *MatchNode:
+				field = append(append([]string{}, prefix...), n.Field...)
+			case *NestedNode:
+				field = append(append([]string{}, prefix...), n.Field...)
+				defer collect(n.Query, field)
+			default:
+				return true
+			}
+
+			if name := strings.Join(field, "."); !seen[name] {
+				seen[name] = true
+				fields = append(fields, name)
+			}
+			return false
+		})
+	}
+	collect(expression.Root(), nil)
+
+	return fields
+}
+
+// Walk traverses the tree rooted at node in depth-first order. It calls visit for every node
+// and descends into children of the node only if visit returns true.
+func Walk(node Node, visit func(Node) bool) {
+	if node == nil || !visit(node) {
+		return
+	}
+
+	for _, child := range children(node) {
+		Walk(child, visit)
+	}
+}
+
+func children(node Node) []Node {
+	switch n := node.(type) {
+	case *OrNode:
+		return n.Children
+	case *AndNode:
+		return n.Children
+	case *NotNode:
+		return []Node{n.Child}
+	case *MatchNode:
+		return []Node{n.Value}
+	case *NestedNode:
+		return []Node{n.Query}
+	case *TermNode:
+		if n.Value != nil {
+			return []Node{n.Value}
+		}
+	case *ValueOrNode:
+		return n.Values
+	case *ValueAndNode:
+		return n.Values
+	case *ValueNotNode:
+		return []Node{n.Value}
+	}
+	return nil
+}
+
+// Rewrite transforms the syntax tree of the expression bottom-up and returns a new expression
+// with the same options. The rewrite function is called for every node after its children
+// have been rewritten. It returns the replacement of the node, the node itself to keep it,
+// or nil to remove it. Removed children of or/and nodes and value lists are dropped.
+func Rewrite(expression Expression, rewrite func(Node) (Node, error)) (Expression, error) {
+	root, err := rewriteNode(expression.Root(), rewrite)
+	if err != nil {
+		return Expression{}, err
+	}
+
+	return NewExpression(root, expression.options)
+}
+
+func rewriteNode(node Node, rewrite func(Node) (Node, error)) (Node, error) {
+	if node == nil {
+		return nil, nil
+	}
+
+	rewriteList := func(nodes []Node) ([]Node, error) {
+		var result []Node
+		for _, child := range nodes {
+			rewritten, err := rewriteNode(child, rewrite)
+			if err != nil {
+				return nil, err
+			}
+			if rewritten != nil {
+				result = append(result, rewritten)
+			}
+		}
+		return result, nil
+	}
+
+	var err error
+	switch n := node.(type) {
+	case *OrNode:
+		copied := *n
+		if copied.Children, err = rewriteList(n.Children); err != nil {
+			return nil, err
+		}
+		if len(copied.Children) == 0 {
+			return nil, nil
+		}
+		node = &copied
+	case *AndNode:
+		copied := *n
+		if copied.Children, err = rewriteList(n.Children); err != nil {
+			return nil, err
+		}
+		if len(copied.Children) == 0 {
+			return nil, nil
+		}
+		node = &copied
+	case *NotNode:
+		copied := *n
+		if copied.Child, err = rewriteNode(n.Child, rewrite); err != nil {
+			return nil, err
+		}
+		if copied.Child == nil {
+			return nil, nil
+		}
+		node = &copied
+	case *MatchNode:
+		copied := *n
+		if copied.Value, err = rewriteNode(n.Value, rewrite); err != nil {
+			return nil, err
+		}
+		if copied.Value == nil {
+			return nil, nil
+		}
+		node = &copied
+	case *NestedNode:
+		copied := *n
+		if copied.Query, err = rewriteNode(n.Query, rewrite); err != nil {
+			return nil, err
+		}
+		if copied.Query == nil {
+			return nil, nil
+		}
+		node = &copied
+	case *TermNode:
+		copied := *n
+		value, err := rewriteNode(n.Value, rewrite)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, nil
+		}
+		valueNode, ok := value.(*ValueNode)
+		if !ok {
+			return nil, fmt.Errorf("term value can not be replaced with %v node", value.Kind())
+		}
+		copied.Value = valueNode
+		node = &copied
+	case *ValueNode:
+		copied := *n
+		node = &copied
+	case *ValueOrNode:
+		copied := *n
+		if copied.Values, err = rewriteList(n.Values); err != nil {
+			return nil, err
+		}
+		if len(copied.Values) == 0 {
+			return nil, nil
+		}
+		node = &copied
+	case *ValueAndNode:
+		copied := *n
+		if copied.Values, err = rewriteList(n.Values); err != nil {
+			return nil, err
+		}
+		if len(copied.Values) == 0 {
+			return nil, nil
+		}
+		node = &copied
+	case *ValueNotNode:
+		copied := *n
+		if copied.Value, err = rewriteNode(n.Value, rewrite); err != nil {
+			return nil, err
+		}
+		if copied.Value == nil {
+			return nil, nil
+		}
+		node = &copied
+	}
+
+	return rewrite(node)
+}
+
+// NewExpression creates an expression from a syntax tree, for example one built by hand or
+// obtained from Expression.Root and modified.
+// Limits of the options other than the length and the depth of the query text apply to it.
+func NewExpression(root Node, options ParseOptions) (Expression, error) {
+	if root == nil {
+		return Expression{}, errors.New("expression is empty")
+	}
+
+	ast, err := toExpression(root)
+	if err != nil {
+		return Expression{}, err
+	}
+	if _, err := checkRegexps(ast, options); err != nil {
+		return Expression{}, err
+	}
+	if err := checkExpressionLimits(ast, options); err != nil {
+		return Expression{}, err
+	}
+	prepare(ast, options)
+
+	return newExpression(ast, options), nil
+}
+
+// ================ AST -> Node  ====================
+
+func (expr *expression) node() Node {
+	return expr.Expr.node()
+}
+
+func (d disjunction) node() Node {
+	if len(d.RightValues) == 0 {
+		return d.LeftValue.node()
+	}
+
+	or := &OrNode{Children: []Node{d.LeftValue.node()}}
+	for _, right := range d.RightValues {
+		or.Children = append(or.Children, right.node())
+	}
+	return or
+}
+
+func (c conjunction) node() Node {
+	if len(c.RightValues) == 0 {
+		return c.LeftValue.node()
+	}
+
+	and := &AndNode{Children: []Node{c.LeftValue.node()}}
+	for _, right := range c.RightValues {
+		and.Children = append(and.Children, right.node())
+	}
+	return and
+}
+
+func (se subExpression) node() Node {
+	var node Node
+	if se.SubExpression != nil {
+		node = se.SubExpression.node()
+	} else if se.FreeText != nil {
+		node = &TermNode{Value: se.FreeText.node()}
+	} else {
+		node = se.Value.node()
+	}
+
+	if se.IsInverted {
+		return &NotNode{Child: node}
+	}
+	return node
+}
+
+func (prop *propertyMatch) node() Node {
+	field := append([]string{}, prop.Name...)
+	if prop.ValueSubExpression != nil {
+		return &NestedNode{Field: field, FieldPattern: prop.pattern != nil, Query: prop.ValueSubExpression.node()}
+	}
+
+	match := &MatchNode{Field: field, FieldPattern: prop.pattern != nil, Operator: prop.Operation}
+	if prop.AtomicValue != nil {
+		match.Value = prop.AtomicValue.node()
+	} else {
+		match.Value = prop.ValueList.node()
+	}
+	return match
+}
+
+func (d *valueDisjunction) node() Node {
+	if len(d.RightValues) == 0 {
+		return d.LeftValue.node()
+	}
+
+	or := &ValueOrNode{Values: []Node{d.LeftValue.node()}}
+	for i := range d.RightValues {
+		or.Values = append(or.Values, d.RightValues[i].node())
+	}
+	return or
+}
+
+func (c *valueConjunction) node() Node {
+	if len(c.RightValues) == 0 {
+		return c.LeftValue.node()
+	}
+
+	and := &ValueAndNode{Values: []Node{c.LeftValue.node()}}
+	for i := range c.RightValues {
+		and.Values = append(and.Values, c.RightValues[i].node())
+	}
+	return and
+}
+
+func (v *valueTerm) node() Node {
+	var node Node
+	if v.ValueList != nil {
+		node = v.ValueList.node()
+	} else {
+		node = v.Value.node()
+	}
+
+	if v.IsInverted {
+		return &ValueNotNode{Value: node}
+	}
+	return node
+}
+
+func (atomic *atomicValue) node() *ValueNode {
+	return &ValueNode{Text: atomic.text(), Quoted: atomic.quoted, Regexp: atomic.regexp != nil}
+}
+
+// ================ Node -> AST  ====================
+
+func toExpression(node Node) (*expression, error) {
+	if or, ok := node.(*OrNode); ok && len(or.Children) > 1 {
+		left, err := toConjunction(or.Children[0])
+		if err != nil {
+			return nil, err
+		}
+
+		d := disjunction{LeftValue: left}
+		for _, child := range or.Children[1:] {
+			right, err := toConjunction(child)
+			if err != nil {
+				return nil, err
+			}
+			d.RightValues = append(d.RightValues, right)
+		}
+		return &expression{Expr: d}, nil
+	}
+
+	c, err := toConjunction(node)
+	if err != nil {
+		return nil, err
+	}
+	return &expression{Expr: disjunction{LeftValue: c}}, nil
+}
+
+func toConjunction(node Node) (conjunction, error) {
+	if and, ok := node.(*AndNode); ok && len(and.Children) > 1 {
+		left, err := toSubExpression(and.Children[0])
+		if err != nil {
+			return conjunction{}, err
+		}
+
+		c := conjunction{LeftValue: left}
+		for _, child := range and.Children[1:] {
+			right, err := toSubExpression(child)
+			if err != nil {
+				return conjunction{}, err
+			}
+			c.RightValues = append(c.RightValues, right)
+		}
+		return c, nil
+	}
+
+	se, err := toSubExpression(node)
+	if err != nil {
+		return conjunction{}, err
+	}
+	return conjunction{LeftValue: se}, nil
+}
+
+func toSubExpression(node Node) (subExpression, error) {
+	switch n := node.(type) {
+	case *OrNode:
+		if len(n.Children) == 0 {
+			return subExpression{}, errors.New("or node has no children")
+		}
+		if len(n.Children) == 1 {
+			return toSubExpression(n.Children[0])
+		}
+		expr, err := toExpression(n)
+		return subExpression{SubExpression: expr}, err
+	case *AndNode:
+		if len(n.Children) == 0 {
+			return subExpression{}, errors.New("and node has no children")
+		}
+		if len(n.Children) == 1 {
+			return toSubExpression(n.Children[0])
+		}
+		expr, err := toExpression(n)
+		return subExpression{SubExpression: expr}, err
+	case *NotNode:
+		if n.Child == nil {
+			return subExpression{}, errors.New("not node has no child")
+		}
+		se, err := toSubExpression(n.Child)
+		if err != nil {
+			return subExpression{}, err
+		}
+		if !se.IsInverted {
+			se.IsInverted = true
+			return se, nil
+		}
+		inner := &expression{Expr: disjunction{LeftValue: conjunction{LeftValue: se}}}
+		return subExpression{IsInverted: true, SubExpression: inner}, nil
+	case *MatchNode:
+		prop, err := toPropertyMatch(n)
+		return subExpression{Value: prop}, err
+	case *NestedNode:
+		if err := checkField(n.Field); err != nil {
+			return subExpression{}, err
+		}
+		if n.Query == nil {
+			return subExpression{}, errors.New("nested node has no query")
+		}
+		query, err := toExpression(n.Query)
+		if err != nil {
+			return subExpression{}, err
+		}
+		prop := &propertyMatch{
+			Operation:          ":",
+			ValueSubExpression: query,
+		}
+		prop.setName(nodeNamePattern(n.Field, n.FieldPattern))
+		return subExpression{Value: prop}, nil
+	case *TermNode:
+		if n.Value == nil {
+			return subExpression{}, errors.New("term node has no value")
+		}
+		atomic, err := toAtomicValue(n.Value)
+		return subExpression{FreeText: atomic}, err
+	case nil:
+		return subExpression{}, errors.New("missing node")
+	}
+
+	return subExpression{}, fmt.Errorf("unexpected %v node in expression", node.Kind())
+}
+
+func toPropertyMatch(n *MatchNode) (*propertyMatch, error) {
+	if err := checkField(n.Field); err != nil {
+		return nil, err
+	}
+
+	prop := &propertyMatch{Operation: n.Operator}
+	prop.setName(nodeNamePattern(n.Field, n.FieldPattern))
+
+	switch n.Operator {
+	case ":", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("unknown operator %q", n.Operator)
+	}
+
+	if value, ok := n.Value.(*ValueNode); ok {
+		if value == nil {
+			return nil, errors.New("match node has no value")
+		}
+		atomic, err := toAtomicValue(value)
+		prop.AtomicValue = atomic
+		return prop, err
+	}
+
+	if n.Value == nil {
+		return nil, errors.New("match node has no value")
+	}
+
+	list, err := toValueDisjunction(n.Value)
+	if err != nil {
+		return nil, err
+	}
+	prop.ValueList = list
+
+	return prop, nil
+}
+
+func toValueDisjunction(node Node) (*valueDisjunction, error) {
+	if or, ok := node.(*ValueOrNode); ok && len(or.Values) > 1 {
+		left, err := toValueConjunction(or.Values[0])
+		if err != nil {
+			return nil, err
+		}
+
+		d := &valueDisjunction{LeftValue: left}
+		for _, value := range or.Values[1:] {
+			right, err := toValueConjunction(value)
+			if err != nil {
+				return nil, err
+			}
+			d.RightValues = append(d.RightValues, right)
+		}
+		return d, nil
+	}
+
+	c, err := toValueConjunction(node)
+	if err != nil {
+		return nil, err
+	}
+	return &valueDisjunction{LeftValue: c}, nil
+}
+
+func toValueConjunction(node Node) (valueConjunction, error) {
+	if and, ok := node.(*ValueAndNode); ok && len(and.Values) > 1 {
+		left, err := toValueTerm(and.Values[0])
+		if err != nil {
+			return valueConjunction{}, err
+		}
+
+		c := valueConjunction{LeftValue: left}
+		for _, value := range and.Values[1:] {
+			right, err := toValueTerm(value)
+			if err != nil {
+				return valueConjunction{}, err
+			}
+			c.RightValues = append(c.RightValues, right)
+		}
+		return c, nil
+	}
+
+	term, err := toValueTerm(node)
+	if err != nil {
+		return valueConjunction{}, err
+	}
+	return valueConjunction{LeftValue: term}, nil
+}
+
+func toValueTerm(node Node) (valueTerm, error) {
+	switch n := node.(type) {
+	case *ValueNode:
+		if n == nil {
+			return valueTerm{}, errors.New("missing value")
+		}
+		atomic, err := toAtomicValue(n)
+		return valueTerm{Value: atomic}, err
+	case *ValueOrNode:
+		if len(n.Values) == 0 {
+			return valueTerm{}, errors.New("value list is empty")
+		}
+		if len(n.Values) == 1 {
+			return toValueTerm(n.Values[0])
+		}
+		list, err := toValueDisjunction(n)
+		return valueTerm{ValueList: list}, err
+	case *ValueAndNode:
+		if len(n.Values) == 0 {
+			return valueTerm{}, errors.New("value list is empty")
+		}
+		if len(n.Values) == 1 {
+			return toValueTerm(n.Values[0])
+		}
+		list, err := toValueDisjunction(n)
+		return valueTerm{ValueList: list}, err
+	case *ValueNotNode:
+		if n.Value == nil {
+			return valueTerm{}, errors.New("value not node has no value")
+		}
+		term, err := toValueTerm(n.Value)
+		if err != nil {
+			return valueTerm{}, err
+		}
+		if !term.IsInverted {
+			term.IsInverted = true
+			return term, nil
+		}
+		list := &valueDisjunction{LeftValue: valueConjunction{LeftValue: term}}
+		return valueTerm{IsInverted: true, ValueList: list}, nil
+	case nil:
+		return valueTerm{}, errors.New("missing value")
+	}
+
+	return valueTerm{}, fmt.Errorf("unexpected %v node in value list", node.Kind())
+}
+
+func toAtomicValue(value *ValueNode) (*atomicValue, error) {
+	var atomic atomicValue
+	if value.Regexp {
+		if err := atomic.setRegexp(value.Text); err != nil {
+			return nil, err
+		}
+		return &atomic, nil
+	}
+	atomic.setValue(value.Text, value.Quoted)
+	return &atomic, nil
+}
+
+// nodeNamePattern returns the parts of the field of a node in pattern form.
+func nodeNamePattern(field []string, pattern bool) []string {
+	names := make([]string, len(field))
+	for i, name := range field {
+		if pattern {
+			names[i] = name
+		} else {
+			names[i] = escapePattern(name)
+		}
+	}
+	return names
+}
+
+func checkField(field []string) error {
+	if len(field) == 0 {
+		return errors.New("field name is empty")
+	}
+	for _, name := range field {
+		if name == "" {
+			return errors.New("field name " + strings.Join(field, ".") + " has an empty part")
+		}
+	}
+	return nil
+}
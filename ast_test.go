@@ -0,0 +1,148 @@
+package gokql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRootRoundTrip(t *testing.T) {
+	queries := []string{
+		"a:1",
+		"a.b.c:'x'",
+		"a:c or b:2 and c:3",
+		"not (a:1 or b:2) and not c:3",
+		"a.b:c or b:2 and (c<=3 or d:{da:a or db:'b'}) or list:(1 or 2 or 3)",
+		"arr:(x and y)",
+		"status:(200 or (3* and not 304))",
+		"status:(not (1 or 2))",
+		"error and not level:info",
+	}
+
+	for _, query := range queries {
+		expr := mustParse(t, query)
+		root := expr.Root()
+		if root.String() != expr.String() {
+			t.Errorf("Node string %s differs from expression string %s", root.String(), expr.String())
+		}
+
+		rebuilt, err := NewExpression(root, ParseOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rebuilt.String() != expr.String() {
+			t.Errorf("Rebuilt expression %s differs from parsed expression %s", rebuilt.String(), expr.String())
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	expr := mustParse(t, "a:1 or (b>2 and not c:(x or y)) or d:{e:1 and f.g:2} or term")
+
+	var kinds []NodeKind
+	Walk(expr.Root(), func(node Node) bool {
+		kinds = append(kinds, node.Kind())
+		return node.Kind() != KindNested
+	})
+
+	expectedKinds := []NodeKind{
+		KindOr,
+		KindMatch, KindValue,
+		KindAnd, KindMatch, KindValue, KindNot, KindMatch, KindValueOr, KindValue, KindValue,
+		KindNested,
+		KindTerm, KindValue,
+	}
+	if !reflect.DeepEqual(kinds, expectedKinds) {
+		t.Errorf("Unexpected visited kinds: %v. Expected: %v", kinds, expectedKinds)
+	}
+
+	expectedFields := []string{"a", "b", "c", "d", "d.e", "d.f.g"}
+	if fields := expr.Fields(); !reflect.DeepEqual(fields, expectedFields) {
+		t.Errorf("Unexpected fields: %v. Expected: %v", fields, expectedFields)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	expr := mustParse(t, "user:admin and (secret:1 or level:info) and not secret:2")
+
+	rewritten, err := Rewrite(expr, func(node Node) (Node, error) {
+		match, ok := node.(*MatchNode)
+		if !ok {
+			return node, nil
+		}
+
+		if match.Field[0] == "secret" {
+			return nil, nil
+		}
+		if match.Field[0] == "user" {
+			return &MatchNode{Field: []string{"account", "name"}, Operator: ":", Value: match.Value}, nil
+		}
+		return node, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rewritten.String() != "(account.name:admin and level:info)" {
+		t.Errorf("Unexpected rewritten expression: %s", rewritten.String())
+	}
+	if expr.String() != "(user:admin and (secret:1 or level:info) and not secret:2)" {
+		t.Errorf("Source expression was modified: %s", expr.String())
+	}
+
+	testExprMap(t, "account.name:admin", map[string]any{"account": map[string]any{"name": "admin"}}, true)
+	ev, err := NewMapEvaluator(map[string]any{
+		"account": map[string]any{"name": "admin"},
+		"level":   "info",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := rewritten.Match(ev); err != nil || !res {
+		t.Errorf("Unexpected match result of rewritten expression: %v, %v", res, err)
+	}
+
+	wildcarded, err := Rewrite(expr, func(node Node) (Node, error) {
+		if value, ok := node.(*ValueNode); ok && value.Text == "admin" {
+			return &ValueNode{Text: "adm*"}, nil
+		}
+		return node, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err = NewMapEvaluator(map[string]any{"user": "administrator", "level": "info"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := wildcarded.Match(ev); err != nil || !res {
+		t.Errorf("Unexpected match result of rewritten wildcard: %v, %v", res, err)
+	}
+
+	if _, err := Rewrite(expr, func(node Node) (Node, error) { return nil, nil }); err == nil {
+		t.Error("Expected error for an empty rewritten expression")
+	}
+}
+
+func TestNewExpressionErrors(t *testing.T) {
+	invalid := []Node{
+		nil,
+		&OrNode{},
+		&NotNode{},
+		&MatchNode{Field: []string{"a"}, Operator: "=", Value: &ValueNode{Text: "1"}},
+		&MatchNode{Field: []string{}, Operator: ":", Value: &ValueNode{Text: "1"}},
+		&MatchNode{Field: []string{"a", ""}, Operator: ":", Value: &ValueNode{Text: "1"}},
+		&MatchNode{Field: []string{"a"}, Operator: ":"},
+		&MatchNode{Field: []string{"a"}, Operator: ":", Value: &ValueOrNode{}},
+		&MatchNode{Field: []string{"a"}, Operator: ":", Value: &ValueNotNode{}},
+		&MatchNode{Field: []string{"a"}, Operator: ":", Value: &AndNode{}},
+		&NestedNode{Field: []string{"a"}},
+		&TermNode{},
+		&ValueNode{Text: "1"},
+	}
+
+	for _, node := range invalid {
+		if _, err := NewExpression(node, ParseOptions{}); err == nil {
+			t.Errorf("Expected error for node %#v", node)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Command gokql filters NDJSON, JSON array and CSV records with a KQL query.
+//
+// Usage:
+//
+//	gokql [flags] query [file ...]
+//
+// Records are read from the files or from the standard input if no files are given,
+// and the matching records are written to the standard output in the input format.
+// The input is processed record by record, so large inputs are filtered in constant memory.
+//
+// Records which cannot be parsed or matched are reported to the standard error with their
+// position and skipped. The exit status is 0 if any record was selected, 1 if none was
+// and 2 if an error occurred.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/vladimir-rom/gokql"
+)
+
+const (
+	exitSelected = 0
+	exitNone     = 1
+	exitError    = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("gokql", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	count := flags.Bool("count", false, "print the number of selected records instead of the records")
+	invert := flags.Bool("invert", false, "select records which do not match the query")
+	fields := flags.String("fields", "", "comma-separated `list` of fields to output, dotted for nested JSON fields")
+	format := flags.String("format", formatAuto, "input `format`: auto, ndjson, json or csv")
+	defaultFields := flags.String("default-fields", "", "comma-separated `list` of fields matched by terms without a field name")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gokql [flags] query [file ...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSelected
+		}
+		return exitError
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitError
+	}
+
+	switch *format {
+	case formatAuto, formatNDJSON, formatJSON, formatCSV:
+	default:
+		fmt.Fprintf(stderr, "gokql: unknown format %q\n", *format)
+		return exitError
+	}
+
+	expression, err := gokql.ParseWithOptions(flags.Arg(0), gokql.ParseOptions{DefaultFields: splitList(*defaultFields)})
+	if err != nil {
+		fmt.Fprintf(stderr, "gokql: invalid query: %v\n", err)
+		var parseError *gokql.ParseError
+		if errors.As(err, &parseError) {
+			fmt.Fprintln(stderr, parseError.Caret())
+		}
+		return exitError
+	}
+
+	output := bufio.NewWriter(stdout)
+	f := &filter{
+		expression: expression,
+		format:     *format,
+		count:      *count,
+		invert:     *invert,
+		fields:     splitList(*fields),
+		output:     output,
+		errors:     stderr,
+	}
+
+	names := flags.Args()[1:]
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	for _, name := range names {
+		if err := f.processFile(name, stdin); err != nil {
+			fmt.Fprintf(stderr, "gokql: %s: %v\n", displayName(name), err)
+			f.failed = true
+		}
+	}
+
+	if err := f.close(); err != nil {
+		fmt.Fprintf(stderr, "gokql: %v\n", err)
+		f.failed = true
+	}
+
+	switch {
+	case f.failed:
+		return exitError
+	case f.selected > 0:
+		return exitSelected
+	default:
+		return exitNone
+	}
+}
+
+// filter selects records of all inputs and writes them to a single output.
+type filter struct {
+	expression gokql.Expression
+	format     string
+	count      bool
+	invert     bool
+	fields     []string
+	output     *bufio.Writer
+	errors     io.Writer
+
+	writer   recordWriter
+	selected int
+	failed   bool
+}
+
+func (f *filter) processFile(name string, stdin io.Reader) error {
+	if name == "-" {
+		return f.process(name, stdin)
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return f.process(name, file)
+}
+
+func (f *filter) process(name string, input io.Reader) error {
+	reader, err := newRecordReader(bufio.NewReader(input), f.format)
+	if err != nil {
+		return err
+	}
+
+	if !f.count {
+		if err := f.prepareWriter(reader); err != nil {
+			return err
+		}
+	}
+
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		var recErr *recordError
+		if errors.As(err, &recErr) {
+			f.reportf("%s: %s: %v", displayName(name), recErr.location, recErr.err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		evaluator, err := gokql.NewMapEvaluator(rec.values)
+		if err != nil {
+			f.reportf("%s: %s: %v", displayName(name), rec.location, err)
+			continue
+		}
+
+		matched, err := f.expression.Match(evaluator)
+		if err != nil {
+			f.reportf("%s: %s: %v", displayName(name), rec.location, err)
+			continue
+		}
+		if matched == f.invert {
+			continue
+		}
+
+		f.selected++
+		if f.count {
+			continue
+		}
+		if err := f.writer.Write(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// prepareWriter creates the output writer for the first input and checks
+// that the following inputs can be written to the same output.
+func (f *filter) prepareWriter(reader recordReader) error {
+	if f.writer == nil {
+		f.writer = newRecordWriter(f.output, reader.Format(), f.fields)
+	}
+	return f.writer.Start(reader.Format(), reader.Header())
+}
+
+func (f *filter) close() error {
+	if f.count {
+		fmt.Fprintln(f.output, f.selected)
+	} else if f.writer != nil {
+		if err := f.writer.Close(); err != nil {
+			return err
+		}
+	}
+
+	return f.output.Flush()
+}
+
+func (f *filter) reportf(format string, args ...interface{}) {
+	fmt.Fprintf(f.errors, "gokql: "+format+"\n", args...)
+	f.failed = true
+}
+
+func displayName(name string) string {
+	if name == "-" {
+		return "<stdin>"
+	}
+	return name
+}
+
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+
+	var result []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runWith(t *testing.T, input string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(input), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestNDJSON(t *testing.T) {
+	input := `{"status":200,"msg":"ok","host":{"name":"a"}}
+{"status":500, "msg":"fail","host":{"name":"b"}}
+
+{"status":404,"tags":["x","y"]}
+`
+
+	testRun := func(args []string, expectedOutput string, expectedCode int) {
+		t.Helper()
+		output, errors, code := runWith(t, input, args...)
+		if output != expectedOutput || code != expectedCode {
+			t.Errorf("Unexpected result for %v: %q, exit code %d, errors %q", args, output, code, errors)
+		}
+	}
+
+	testRun([]string{"status>=400"}, `{"status":500, "msg":"fail","host":{"name":"b"}}`+"\n"+`{"status":404,"tags":["x","y"]}`+"\n", exitSelected)
+	testRun([]string{"tags:y"}, `{"status":404,"tags":["x","y"]}`+"\n", exitSelected)
+	testRun([]string{"host:{name:a}"}, `{"status":200,"msg":"ok","host":{"name":"a"}}`+"\n", exitSelected)
+	testRun([]string{"status:1"}, "", exitNone)
+	testRun([]string{"--count", "status>=400"}, "2\n", exitSelected)
+	testRun([]string{"--count", "status:1"}, "0\n", exitNone)
+	testRun([]string{"--invert", "--count", "status>=400"}, "1\n", exitSelected)
+	testRun([]string{"--fields", "host.name,status,missing", "status:(200 or 500)"}, `{"host":{"name":"a"},"status":200}`+"\n"+`{"host":{"name":"b"},"status":500}`+"\n", exitSelected)
+	testRun([]string{"--default-fields", "msg", "fail"}, `{"status":500, "msg":"fail","host":{"name":"b"}}`+"\n", exitSelected)
+}
+
+func TestJSONArray(t *testing.T) {
+	input := `[
+  {"a": 1, "b": "<x>"},
+  {"a": 2}
+]`
+
+	output, errors, code := runWith(t, input, "a:1")
+	if expected := "[\n{\"a\":1,\"b\":\"<x>\"}\n]\n"; output != expected || code != exitSelected {
+		t.Errorf("Unexpected result: %q, exit code %d, errors %q", output, code, errors)
+	}
+
+	output, errors, code = runWith(t, input, "--fields", "b", "a:1")
+	if expected := "[\n{\"b\":\"<x>\"}\n]\n"; output != expected || code != exitSelected {
+		t.Errorf("Unexpected projected result: %q, exit code %d, errors %q", output, code, errors)
+	}
+
+	output, errors, code = runWith(t, input, "a:3")
+	if output != "[]\n" || code != exitNone {
+		t.Errorf("Unexpected empty result: %q, exit code %d, errors %q", output, code, errors)
+	}
+
+	output, errors, code = runWith(t, `[{"a":1}, 5, {"a":1}]`, "a:1")
+	if expected := "[\n{\"a\":1},\n{\"a\":1}\n]\n"; output != expected || code != exitError || !strings.Contains(errors, "<stdin>: record 2:") {
+		t.Errorf("Unexpected result for invalid record: %q, exit code %d, errors %q", output, code, errors)
+	}
+
+	_, errors, code = runWith(t, `[{"a":1}, {"a":`, "a:1")
+	if code != exitError || !strings.Contains(errors, "record 2") {
+		t.Errorf("Unexpected result for truncated input: exit code %d, errors %q", code, errors)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	input := "name,status,zip\nweb,200,007\n\"db, primary\",1000,123\n"
+
+	testRun := func(args []string, expectedOutput string, expectedCode int) {
+		t.Helper()
+		output, errors, code := runWith(t, input, args...)
+		if output != expectedOutput || code != expectedCode {
+			t.Errorf("Unexpected result for %v: %q, exit code %d, errors %q", args, output, code, errors)
+		}
+	}
+
+	testRun([]string{"status>500"}, "name,status,zip\n\"db, primary\",1000,123\n", exitSelected)
+	testRun([]string{"zip:007"}, "name,status,zip\nweb,200,007\n", exitSelected)
+	testRun([]string{"name:db*"}, "name,status,zip\n\"db, primary\",1000,123\n", exitSelected)
+	testRun([]string{"--fields", "zip,name", "status:200"}, "zip,name\n007,web\n", exitSelected)
+	testRun([]string{"status:1"}, "name,status,zip\n", exitNone)
+
+	output, errors, code := runWith(t, input, "--fields", "missing", "status:200")
+	if output != "" || code != exitError || !strings.Contains(errors, "unknown CSV column missing") {
+		t.Errorf("Unexpected result for unknown column: %q, exit code %d, errors %q", output, code, errors)
+	}
+
+	output, errors, code = runWith(t, "a,b\n1,2\n3\n4,5\n", "--count", "a>0")
+	if output != "2\n" || code != exitError || !strings.Contains(errors, "<stdin>: line 3:") {
+		t.Errorf("Unexpected result for invalid row: %q, exit code %d, errors %q", output, code, errors)
+	}
+}
+
+func TestRecordErrors(t *testing.T) {
+	output, errors, code := runWith(t, "{\"a\":1}\nnot json\n{\"a\":{\"b\":1}}\n{\"a\":1}\n", "a:1")
+	if output != "{\"a\":1}\n{\"a\":1}\n" || code != exitError {
+		t.Errorf("Unexpected result: %q, exit code %d", output, code)
+	}
+	if !strings.Contains(errors, "gokql: <stdin>: line 2:") {
+		t.Errorf("Record error is not reported: %q", errors)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.ndjson")
+	second := filepath.Join(dir, "second.ndjson")
+	other := filepath.Join(dir, "other.csv")
+	writeFile(t, first, `{"a":1}`+"\n")
+	writeFile(t, second, `{"a":2}`+"\n"+`{"a":1,"b":2}`+"\n")
+	writeFile(t, other, "a\n1\n")
+
+	output, errors, code := runWith(t, "", "a:1", first, second)
+	if output != `{"a":1}`+"\n"+`{"a":1,"b":2}`+"\n" || code != exitSelected {
+		t.Errorf("Unexpected result: %q, exit code %d, errors %q", output, code, errors)
+	}
+
+	_, errors, code = runWith(t, "", "a:1", first, other, filepath.Join(dir, "missing"))
+	if code != exitError || !strings.Contains(errors, "other.csv: csv input") || !strings.Contains(errors, "missing:") {
+		t.Errorf("Unexpected result for incompatible inputs: exit code %d, errors %q", code, errors)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	testError := func(expectedError string, args ...string) {
+		t.Helper()
+		_, errors, code := runWith(t, "", args...)
+		if code != exitError || !strings.Contains(errors, expectedError) {
+			t.Errorf("Unexpected result for %v: exit code %d, errors %q", args, code, errors)
+		}
+	}
+
+	testError("Usage:")
+	testError(`unknown format "xml"`, "--format", "xml", "a:1")
+	testError("status=1\n      ^", "status=1")
+	testError("flag provided but not defined", "--unknown", "a:1")
+}
+
+func writeFile(t *testing.T, name string, content string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	formatAuto   = "auto"
+	formatNDJSON = "ndjson"
+	formatJSON   = "json"
+	formatCSV    = "csv"
+)
+
+// record is a single input record.
+type record struct {
+	// values are the fields of the record the query is matched against.
+	values map[string]interface{}
+	// raw is the original JSON of a JSON record.
+	raw []byte
+	// row is the original row of a CSV record.
+	row []string
+	// location describes the position of the record in its input.
+	location string
+}
+
+// recordError is returned by a recordReader when the current record is invalid
+// but the following records can still be read.
+type recordError struct {
+	location string
+	err      error
+}
+
+func (e *recordError) Error() string {
+	return e.location + ": " + e.err.Error()
+}
+
+type recordReader interface {
+	// Read returns the next record or io.EOF at the end of the input.
+	Read() (*record, error)
+	// Format returns the format of the input.
+	Format() string
+	// Header returns the column names of a CSV input.
+	Header() []string
+}
+
+func newRecordReader(input *bufio.Reader, format string) (recordReader, error) {
+	if format == formatAuto {
+		format = detectFormat(input)
+	}
+
+	switch format {
+	case formatJSON:
+		return &jsonArrayReader{decoder: json.NewDecoder(input)}, nil
+	case formatCSV:
+		return newCSVReader(input)
+	default:
+		return &ndjsonReader{input: input}, nil
+	}
+}
+
+// detectFormat looks at the first non-whitespace character of the input:
+// '[' starts a JSON array, '{' starts NDJSON and anything else is a CSV header.
+func detectFormat(input *bufio.Reader) string {
+	for n := 1; ; n++ {
+		buf, _ := input.Peek(n)
+		if len(buf) < n {
+			return formatNDJSON
+		}
+
+		switch buf[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			return formatJSON
+		case '{':
+			return formatNDJSON
+		default:
+			return formatCSV
+		}
+	}
+}
+
+type ndjsonReader struct {
+	input *bufio.Reader
+	line  int
+}
+
+func (r *ndjsonReader) Read() (*record, error) {
+	for {
+		line, err := r.input.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+		r.line++
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		location := fmt.Sprintf("line %d", r.line)
+		var values map[string]interface{}
+		if err := json.Unmarshal(line, &values); err != nil {
+			return nil, &recordError{location, err}
+		}
+
+		return &record{values: values, raw: line, location: location}, nil
+	}
+}
+
+func (r *ndjsonReader) Format() string {
+	return formatNDJSON
+}
+
+func (r *ndjsonReader) Header() []string {
+	return nil
+}
+
+type jsonArrayReader struct {
+	decoder *json.Decoder
+	index   int
+	started bool
+	done    bool
+}
+
+func (r *jsonArrayReader) Read() (*record, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+
+	if !r.started {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return nil, errors.New("input is not a JSON array")
+		}
+		r.started = true
+	}
+
+	if !r.decoder.More() {
+		if _, err := r.decoder.Token(); err != nil {
+			return nil, err
+		}
+		r.done = true
+		return nil, io.EOF
+	}
+
+	r.index++
+	location := fmt.Sprintf("record %d", r.index)
+
+	var raw json.RawMessage
+	if err := r.decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", location, err)
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, &recordError{location, err}
+	}
+
+	return &record{values: values, raw: raw, location: location}, nil
+}
+
+func (r *jsonArrayReader) Format() string {
+	return formatJSON
+}
+
+func (r *jsonArrayReader) Header() []string {
+	return nil
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func newCSVReader(input io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(input)
+	header, err := reader.Read()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	return &csvReader{reader: reader, header: header}, nil
+}
+
+func (r *csvReader) Read() (*record, error) {
+	if r.header == nil {
+		return nil, io.EOF
+	}
+
+	row, err := r.reader.Read()
+	if err != nil {
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			return nil, &recordError{fmt.Sprintf("line %d", parseError.StartLine), parseError.Err}
+		}
+		return nil, err
+	}
+
+	line, _ := r.reader.FieldPos(0)
+	values := make(map[string]interface{}, len(row))
+	for i, name := range r.header {
+		values[name] = csvValue(row[i])
+	}
+
+	return &record{values: values, row: row, location: fmt.Sprintf("line %d", line)}, nil
+}
+
+func (r *csvReader) Format() string {
+	return formatCSV
+}
+
+func (r *csvReader) Header() []string {
+	return r.header
+}
+
+// csvValue converts a CSV cell which holds a decimal number to float64,
+// so range queries compare it numerically. Other cells are kept as strings.
+func csvValue(cell string) interface{} {
+	if cell == "" || strings.Trim(cell, "0123456789+-.eE") != "" {
+		return cell
+	}
+
+	if number, err := strconv.ParseFloat(cell, 64); err == nil {
+		return number
+	}
+	return cell
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type recordWriter interface {
+	// Start is called before records of an input are written. It returns an error
+	// if records of the input cannot be written to the same output as the previous inputs.
+	Start(format string, header []string) error
+	Write(rec *record) error
+	Close() error
+}
+
+func newRecordWriter(output *bufio.Writer, format string, fields []string) recordWriter {
+	switch format {
+	case formatCSV:
+		return &csvWriter{writer: csv.NewWriter(output), fields: fields}
+	case formatJSON:
+		return &jsonArrayWriter{output: output, paths: splitPaths(fields)}
+	default:
+		return &ndjsonWriter{output: output, paths: splitPaths(fields)}
+	}
+}
+
+func checkFormat(expected string, actual string) error {
+	if actual != expected {
+		return fmt.Errorf("%s input cannot be written to %s output of the previous inputs", actual, expected)
+	}
+	return nil
+}
+
+type ndjsonWriter struct {
+	output *bufio.Writer
+	paths  [][]string
+}
+
+func (w *ndjsonWriter) Start(format string, header []string) error {
+	return checkFormat(formatNDJSON, format)
+}
+
+func (w *ndjsonWriter) Write(rec *record) error {
+	data := rec.raw
+	if len(w.paths) > 0 {
+		var err error
+		if data, err = jsonRecord(rec, w.paths); err != nil {
+			return err
+		}
+	}
+
+	w.output.Write(data)
+	return w.output.WriteByte('\n')
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
+
+type jsonArrayWriter struct {
+	output  *bufio.Writer
+	paths   [][]string
+	written int
+}
+
+func (w *jsonArrayWriter) Start(format string, header []string) error {
+	return checkFormat(formatJSON, format)
+}
+
+func (w *jsonArrayWriter) Write(rec *record) error {
+	data, err := jsonRecord(rec, w.paths)
+	if err != nil {
+		return err
+	}
+
+	if w.written == 0 {
+		w.output.WriteString("[\n")
+	} else {
+		w.output.WriteString(",\n")
+	}
+	w.written++
+
+	_, err = w.output.Write(data)
+	return err
+}
+
+func (w *jsonArrayWriter) Close() error {
+	if w.written == 0 {
+		_, err := w.output.WriteString("[]\n")
+		return err
+	}
+
+	_, err := w.output.WriteString("\n]\n")
+	return err
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+	fields []string
+	header []string
+	// columns are indexes of the output columns in rows of the current input.
+	columns []int
+}
+
+func (w *csvWriter) Start(format string, header []string) error {
+	if err := checkFormat(formatCSV, format); err != nil {
+		return err
+	}
+	if header == nil {
+		return nil
+	}
+
+	if len(w.fields) == 0 {
+		if w.header == nil {
+			w.header = header
+			return w.writer.Write(header)
+		}
+		if strings.Join(header, "\x00") != strings.Join(w.header, "\x00") {
+			return fmt.Errorf("CSV header %v differs from header %v of the previous inputs", header, w.header)
+		}
+		return nil
+	}
+
+	w.columns = w.columns[:0]
+	for _, field := range w.fields {
+		column := -1
+		for i, name := range header {
+			if name == field {
+				column = i
+				break
+			}
+		}
+		if column == -1 {
+			return fmt.Errorf("unknown CSV column %s", field)
+		}
+		w.columns = append(w.columns, column)
+	}
+
+	if w.header == nil {
+		w.header = w.fields
+		return w.writer.Write(w.fields)
+	}
+	return nil
+}
+
+func (w *csvWriter) Write(rec *record) error {
+	if len(w.fields) == 0 {
+		return w.writer.Write(rec.row)
+	}
+
+	row := make([]string, len(w.columns))
+	for i, column := range w.columns {
+		row[i] = rec.row[column]
+	}
+	return w.writer.Write(row)
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// jsonRecord returns the compacted original JSON of the record,
+// or only the fields at paths if a projection is requested.
+func jsonRecord(rec *record, paths [][]string) ([]byte, error) {
+	if len(paths) == 0 {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, rec.raw); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(project(rec.values, paths)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// project copies the values at paths into a new object keeping their nesting.
+// Paths which are missing in the values are skipped.
+func project(values map[string]interface{}, paths [][]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(paths))
+	for _, path := range paths {
+		value, ok := lookup(values, path)
+		if !ok {
+			continue
+		}
+
+		target := result
+		for _, name := range path[:len(path)-1] {
+			next, ok := target[name].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				target[name] = next
+			}
+			target = next
+		}
+		target[path[len(path)-1]] = value
+	}
+	return result
+}
+
+func lookup(values map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = values
+	for _, name := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[name]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func splitPaths(fields []string) [][]string {
+	paths := make([][]string, len(fields))
+	for i, field := range fields {
+		paths[i] = strings.Split(field, ".")
+	}
+	return paths
+}
@@ -0,0 +1,388 @@
+package gokql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// compiledMatch matches a value of the type the expression was compiled for.
+type compiledMatch func(value reflect.Value) (bool, error)
+
+// fieldAccessor returns a field of a struct value resolved at compile time.
+// It returns false if the field is not reachable because of a nil pointer.
+type fieldAccessor func(value reflect.Value) (reflect.Value, bool)
+
+// CompileFor compiles the expression into a matcher specialized for struct type T.
+//
+// Property paths, nested sub-expressions and type handlers are resolved once for the type,
+// so matching an item does not look its fields up by name. Unknown or unexported properties
+// and query values which cannot be converted to the property type are reported by CompileFor
+// instead of silently not matching.
+func CompileFor[T any](expression Expression) (func(*T) (bool, error), error) {
+	if expression.ast == nil {
+		return nil, errors.New("expression is not initialized")
+	}
+
+	itemType := reflect.TypeOf((*T)(nil)).Elem()
+	compiler := structCompiler{state: expression.newMatchState()}
+	match, err := compiler.expression(expression.ast, itemType)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(item *T) (bool, error) {
+		if item == nil {
+			return false, nil
+		}
+		return match(reflect.ValueOf(item).Elem())
+	}, nil
+}
+
+type structCompiler struct {
+	state *matchState
+}
+
+func (c structCompiler) expression(expr *expression, valueType reflect.Type) (compiledMatch, error) {
+	return c.disjunction(expr.Expr, valueType)
+}
+
+func (c structCompiler) disjunction(d disjunction, valueType reflect.Type) (compiledMatch, error) {
+	matches := make([]compiledMatch, 0, len(d.RightValues)+1)
+	for _, conj := range append([]conjunction{d.LeftValue}, d.RightValues...) {
+		match, err := c.conjunction(conj, valueType)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+
+	return func(value reflect.Value) (bool, error) {
+		for _, match := range matches {
+			res, err := match(value)
+			if err != nil {
+				return false, err
+			}
+			if res {
+				return true, nil
+			}
+		}
+		return false, nil
+	}, nil
+}
+
+func (c structCompiler) conjunction(conj conjunction, valueType reflect.Type) (compiledMatch, error) {
+	matches := make([]compiledMatch, 0, len(conj.RightValues)+1)
+	for _, se := range append([]subExpression{conj.LeftValue}, conj.RightValues...) {
+		match, err := c.subExpression(se, valueType)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+
+	return func(value reflect.Value) (bool, error) {
+		for _, match := range matches {
+			res, err := match(value)
+			if err != nil {
+				return false, err
+			}
+			if !res {
+				return false, nil
+			}
+		}
+		return true, nil
+	}, nil
+}
+
+func (c structCompiler) subExpression(se subExpression, valueType reflect.Type) (compiledMatch, error) {
+	var match compiledMatch
+	var err error
+	if se.SubExpression != nil {
+		match, err = c.expression(se.SubExpression, valueType)
+	} else if se.FreeText != nil {
+		match, err = c.freeText(se.FreeText, valueType)
+	} else {
+		match, err = c.propertyMatch(se.Value, valueType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !se.IsInverted {
+		return match, nil
+	}
+
+	return func(value reflect.Value) (bool, error) {
+		res, err := match(value)
+		if err != nil {
+			return false, err
+		}
+		return !res, nil
+	}, nil
+}
+
+func (c structCompiler) propertyMatch(prop *propertyMatch, valueType reflect.Type) (compiledMatch, error) {
+	if prop.pattern != nil {
+		// fields matching the pattern depend on the keys of maps and on the dynamic types of interfaces,
+		// so they are enumerated when matching
+		state := c.state
+		return func(value reflect.Value) (bool, error) {
+			return prop.match(NewReflectEvaluator(value.Interface()), state)
+		}, nil
+	}
+
+	accessor, fieldType, err := resolveField(valueType, prop.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if prop.ValueSubExpression != nil {
+		return c.nested(prop, accessor, fieldType)
+	}
+
+	if err := c.resolveComparers(prop, fieldType); err != nil {
+		return nil, err
+	}
+
+	state := c.state
+	return func(value reflect.Value) (bool, error) {
+		field, ok := accessor(value)
+		if !ok {
+			return false, nil
+		}
+
+		property, ok := fieldInterface(field)
+		if !ok {
+			return false, nil
+		}
+
+		return prop.matchProperty(property, state)
+	}, nil
+}
+
+func (c structCompiler) nested(prop *propertyMatch, accessor fieldAccessor, fieldType reflect.Type) (compiledMatch, error) {
+	fieldType, derefs := indirectType(fieldType)
+	c = structCompiler{state: c.state.nested(strings.Join(prop.Name, "."))}
+
+	if fieldType.Kind() == reflect.Struct {
+		match, err := c.expression(prop.ValueSubExpression, fieldType)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(value reflect.Value) (bool, error) {
+			field, ok := accessor(value)
+			if !ok {
+				return false, nil
+			}
+			if field, ok = deref(field, derefs); !ok {
+				return false, nil
+			}
+			return match(field)
+		}, nil
+	}
+
+	if fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array {
+		elemType, elemDerefs := indirectType(fieldType.Elem())
+		if elemType.Kind() == reflect.Struct {
+			match, err := c.expression(prop.ValueSubExpression, elemType)
+			if err != nil {
+				return nil, err
+			}
+
+			return func(value reflect.Value) (bool, error) {
+				field, ok := accessor(value)
+				if !ok {
+					return false, nil
+				}
+				if field, ok = deref(field, derefs); !ok {
+					return false, nil
+				}
+
+				for i := 0; i < field.Len(); i++ {
+					elem, ok := deref(field.Index(i), elemDerefs)
+					if !ok {
+						continue
+					}
+
+					res, err := match(elem)
+					if err != nil {
+						return false, err
+					}
+					if res {
+						return true, nil
+					}
+				}
+				return false, nil
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("property %s of type %s does not support nested queries", strings.Join(prop.Name, "."), fieldType)
+}
+
+func (c structCompiler) freeText(atomic *atomicValue, valueType reflect.Type) (compiledMatch, error) {
+	var accessors []fieldAccessor
+	var paths []string
+	if len(c.state.defaultFields) > 0 {
+		for _, field := range c.state.defaultFields {
+			accessor, _, err := resolveField(valueType, field)
+			if err != nil {
+				return nil, err
+			}
+			accessors = append(accessors, accessor)
+			paths = append(paths, c.state.fieldPath(c.state.prefix, field...))
+		}
+	} else {
+		structType, derefs := indirectType(valueType)
+		if structType.Kind() == reflect.Struct {
+			for _, field := range cachedStructFields(structType, defaultTags).list {
+				accessors = append(accessors, newFieldAccessor([]fieldStep{{derefs, field.index}}))
+				paths = append(paths, c.state.fieldPath(c.state.prefix, field.name))
+			}
+		}
+	}
+
+	state := c.state
+	return func(value reflect.Value) (bool, error) {
+		for i, accessor := range accessors {
+			field, ok := accessor(value)
+			if !ok {
+				continue
+			}
+
+			property, ok := fieldInterface(field)
+			if !ok {
+				continue
+			}
+
+			if !isNestedValue(property) || atomic.hasTypeHandler(property) {
+				if matchFreeTextValue(property, state.schemaValue(atomic, paths[i])) {
+					return true, nil
+				}
+				continue
+			}
+
+			res, err := matchNestedFreeText(NewReflectEvaluator(property), atomic, state, paths[i]+".", 0)
+			if err != nil {
+				return false, err
+			}
+			if res {
+				return true, nil
+			}
+		}
+		return false, nil
+	}, nil
+}
+
+// resolveComparers creates comparers of the property match for the field type ahead of time,
+// so conversion errors of query values are reported at compile time.
+func (c structCompiler) resolveComparers(prop *propertyMatch, fieldType reflect.Type) error {
+	fieldType, _ = indirectType(fieldType)
+
+	var atomics []*atomicValue
+	prop.visit(visitor{
+		atomicValue: func(atomic *atomicValue) {
+			atomics = append(atomics, atomic)
+		},
+	})
+
+	cmp := operationComparer(prop.Operation)
+	for _, atomic := range atomics {
+		valueType := fieldType
+		if valueType.Kind() == reflect.Slice && atomic.isSliceProperty(reflect.Zero(valueType).Interface()) {
+			valueType = valueType.Elem()
+		}
+		if valueType.Kind() == reflect.Interface {
+			continue
+		}
+
+		if _, err := compare(reflect.Zero(valueType).Interface(), atomic, cmp); err != nil {
+			return fmt.Errorf("property %s: %w", strings.Join(prop.Name, "."), err)
+		}
+	}
+
+	return nil
+}
+
+type fieldStep struct {
+	derefs int
+	index  []int
+}
+
+func resolveField(valueType reflect.Type, names []string) (fieldAccessor, reflect.Type, error) {
+	steps := make([]fieldStep, len(names))
+	for i, name := range names {
+		structType, derefs := indirectType(valueType)
+		if structType.Kind() != reflect.Struct {
+			return nil, nil, fmt.Errorf("property %s of type %s has no fields", strings.Join(names[:i], "."), valueType)
+		}
+
+		field, ok := cachedStructFields(structType, defaultTags).lookup(name)
+		if !ok {
+			if field, ok := structType.FieldByName(name); ok && !field.IsExported() {
+				return nil, nil, &UnexportedFieldError{Type: structType, Field: strings.Join(names[:i+1], ".")}
+			}
+			return nil, nil, fmt.Errorf("unknown property %s of type %s", strings.Join(names[:i+1], "."), structType)
+		}
+
+		steps[i] = fieldStep{derefs, field.index}
+		valueType = structType.FieldByIndex(field.index).Type
+	}
+
+	return newFieldAccessor(steps), valueType, nil
+}
+
+func newFieldAccessor(steps []fieldStep) fieldAccessor {
+	return func(value reflect.Value) (reflect.Value, bool) {
+		for _, step := range steps {
+			var ok bool
+			if value, ok = deref(value, step.derefs); !ok {
+				return reflect.Value{}, false
+			}
+
+			var err error
+			if value, err = value.FieldByIndexErr(step.index); err != nil {
+				return reflect.Value{}, false
+			}
+		}
+		return value, true
+	}
+}
+
+// fieldInterface returns the value of a field dereferencing pointers. Nil pointers and interfaces are reported as missing values.
+func fieldInterface(field reflect.Value) (interface{}, bool) {
+	if field = indirectValue(field); !field.IsValid() {
+		return nil, false
+	}
+	return field.Interface(), true
+}
+
+func indirectType(valueType reflect.Type) (reflect.Type, int) {
+	derefs := 0
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+		derefs++
+	}
+	return valueType, derefs
+}
+
+func deref(value reflect.Value, derefs int) (reflect.Value, bool) {
+	for i := 0; i < derefs; i++ {
+		if value.IsNil() {
+			return reflect.Value{}, false
+		}
+		value = value.Elem()
+	}
+	return value, true
+}
@@ -0,0 +1,151 @@
+package gokql
+
+import (
+	"testing"
+	"time"
+)
+
+type compileNested struct {
+	Name string
+	Tags []string
+}
+
+type compileEmbedded struct {
+	Region string
+}
+
+type compileItem struct {
+	compileEmbedded
+	Status   int
+	Message  string
+	Created  time.Time
+	Nested   compileNested
+	Pointer  *compileNested
+	Items    []compileNested
+	PtrItems []*compileNested
+	Any      interface{}
+	hidden   string
+}
+
+func TestCompileFor(t *testing.T) {
+	item := compileItem{
+		compileEmbedded: compileEmbedded{"eu"},
+		Status:          200,
+		Message:         "connection refused",
+		Created:         time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Nested:          compileNested{"inner", []string{"a", "b"}},
+		Items:           []compileNested{{"first", nil}, {"second", []string{"x"}}},
+		PtrItems:        []*compileNested{nil, {"ptr", nil}},
+		Any:             "dynamic",
+		hidden:          "secret",
+	}
+
+	testCompiled := func(query string, expected bool) {
+		t.Helper()
+		expr, err := Parse(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		match, err := CompileFor[compileItem](expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := match(&item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != expected {
+			t.Errorf("Unexpected match result: %v for expression %s", result, query)
+		}
+	}
+
+	testCompiled("Status:200", true)
+	testCompiled("Status>=300", false)
+	testCompiled("Status:(100 or 200)", true)
+	testCompiled("Status:(not (100 or 300))", true)
+	testCompiled("Message:connection*", true)
+	testCompiled("Region:eu", true)
+	testCompiled("Created>'2021-05-17T01:00:00Z'", true)
+	testCompiled("Nested.Name:inner and Nested.Tags:b", true)
+	testCompiled("Nested.Tags:(a and c)", false)
+	testCompiled("Nested.Tags>a", true)
+	testCompiled("Nested.Tags>b", false)
+	testCompiled("Nested:{Name:inner and Tags:a}", true)
+	testCompiled("Pointer.Name:inner", false)
+	testCompiled("Pointer:{Name:inner}", false)
+	testCompiled("Items:{Name:second and Tags:x}", true)
+	testCompiled("Items:{Name:first and Tags:x}", false)
+	testCompiled("PtrItems:{Name:ptr}", true)
+	testCompiled("Any:dynamic", true)
+	testCompiled("not Status:200 or Message:x", false)
+	testCompiled("refused or inner", true)
+	testCompiled("secret", false)
+
+	if match, err := CompileFor[compileItem](mustParse(t, "Status:1")); err != nil {
+		t.Fatal(err)
+	} else if res, err := match(nil); err != nil || res {
+		t.Errorf("Unexpected match result for nil item: %v, %v", res, err)
+	}
+
+	pointerItem := compileItem{Pointer: &compileNested{Name: "inner"}}
+	match, err := CompileFor[compileItem](mustParse(t, "Pointer.Name:inner and Pointer:{Name:inner}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := match(&pointerItem); err != nil || !res {
+		t.Errorf("Unexpected match result for pointer field: %v, %v", res, err)
+	}
+
+	defaultFields, err := ParseWithOptions("inner", ParseOptions{DefaultFields: []string{"Message", "Nested.Name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	match, err = CompileFor[compileItem](defaultFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := match(&item); err != nil || !res {
+		t.Errorf("Unexpected match result for default fields: %v, %v", res, err)
+	}
+}
+
+func TestCompileForErrors(t *testing.T) {
+	testCompileError := func(query string) {
+		t.Helper()
+		if _, err := CompileFor[compileItem](mustParse(t, query)); err == nil {
+			t.Errorf("Expected compile error for %s", query)
+		}
+	}
+
+	testCompileError("NotExisted:1")
+	testCompileError("Nested.NotExisted:1")
+	testCompileError("Status.Name:1")
+	testCompileError("hidden:secret")
+	testCompileError("Status:abc")
+	testCompileError("Status:(200 or (abc and not 300))")
+	testCompileError("Status:{Name:a}")
+	testCompileError("Nested:{NotExisted:a}")
+
+	if _, err := CompileFor[compileItem](Expression{}); err == nil {
+		t.Error("Expected error for an empty expression")
+	}
+
+	defaultFields, err := ParseWithOptions("a", ParseOptions{DefaultFields: []string{"NotExisted"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CompileFor[compileItem](defaultFields); err == nil {
+		t.Error("Expected compile error for unknown default field")
+	}
+}
+
+func mustParse(t *testing.T, query string) Expression {
+	t.Helper()
+	expr, err := Parse(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return expr
+}
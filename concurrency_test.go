@@ -0,0 +1,121 @@
+package gokql
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// The tests in this file are meant to be run with the race detector: go test -race ./...
+
+func TestConcurrentMatchMixedTypes(t *testing.T) {
+	expr, err := Parse("value:1 or value:2 or value:3 or (value>=10 and value<20) or list:1 or nested:{value:1}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type item struct {
+		value    interface{}
+		expected bool
+	}
+
+	items := []item{
+		{1, true},
+		{int8(2), true},
+		{int16(15), true},
+		{int32(5), false},
+		{int64(3), true},
+		{uint(1), true},
+		{uint8(12), true},
+		{uint16(7), false},
+		{uint32(19), true},
+		{uint64(25), false},
+		{float32(1), true},
+		{float64(11.5), true},
+		{"1", true},
+		{"4", false},
+	}
+
+	evaluators := make([]Evaluator, len(items))
+	for i, it := range items {
+		ev, err := NewMapEvaluator(map[string]any{
+			"value": it.value,
+			"list":  []any{it.value},
+			"nested": map[string]any{
+				"value": it.value,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		evaluators[i] = ev
+	}
+
+	const goroutines = 32
+	const iterations = 200
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				index := (offset + i) % len(items)
+				result, err := expr.Match(evaluators[index])
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				if result != items[index].expected {
+					t.Errorf("Unexpected match result %v for value %#v", result, items[index].value)
+					return
+				}
+			}
+		}(g)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentMatchStructs(t *testing.T) {
+	expr, err := Parse("Pstring:val* and (Pint>1 or Pfloat64<='2.5' or Ptime>'2021-05-17T01:00:00Z') and not Pbool:true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := []testStruct{
+		{Pstring: "value", Pint: 2},
+		{Pstring: "value", Pfloat64: 1},
+		{Pstring: "value", Ptime: time.Now()},
+		{Pstring: "value", Pint: 2, Pbool: true},
+		{Pstring: "other", Pint: 2},
+	}
+	expected := []bool{true, true, true, false, false}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				index := (offset + i) % len(items)
+				result, err := expr.Match(NewReflectEvaluator(items[index]))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if result != expected[index] {
+					t.Errorf("Unexpected match result %v for item %d", result, index)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
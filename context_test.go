@@ -0,0 +1,132 @@
+package gokql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type contextKey struct{}
+
+// fetchingEvaluator is a ContextEvaluator which records the properties it fetches
+// and calls onFetch before returning them.
+type fetchingEvaluator struct {
+	*MapEvaluator
+	fetched *[]string
+	onFetch func(ctx context.Context) error
+}
+
+func newFetchingEvaluator(t *testing.T, obj map[string]interface{}, onFetch func(ctx context.Context) error) fetchingEvaluator {
+	t.Helper()
+	evaluator, err := NewMapEvaluator(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fetchingEvaluator{evaluator, new([]string), onFetch}
+}
+
+func (e fetchingEvaluator) EvaluateContext(ctx context.Context, propertyName string) (interface{}, error) {
+	*e.fetched = append(*e.fetched, propertyName)
+	if err := e.onFetch(ctx); err != nil {
+		return nil, err
+	}
+	return e.Evaluate(propertyName)
+}
+
+func (e fetchingEvaluator) GetSubEvaluator(propertyName string) (Evaluator, error) {
+	subEvaluator, err := e.MapEvaluator.GetSubEvaluator(propertyName)
+	if err != nil || subEvaluator == nil {
+		return subEvaluator, err
+	}
+	return fetchingEvaluator{subEvaluator.(*MapEvaluator), e.fetched, e.onFetch}, nil
+}
+
+func (e fetchingEvaluator) GetArraySubEvaluators() ([]Evaluator, error) {
+	subEvaluators, err := e.MapEvaluator.GetArraySubEvaluators()
+	for i, subEvaluator := range subEvaluators {
+		subEvaluators[i] = fetchingEvaluator{subEvaluator.(*MapEvaluator), e.fetched, e.onFetch}
+	}
+	return subEvaluators, err
+}
+
+func TestMatchContext(t *testing.T) {
+	obj := map[string]interface{}{
+		"a":     1,
+		"b":     "x",
+		"items": []interface{}{map[string]interface{}{"c": 1}, map[string]interface{}{"c": 2}},
+		"n":     map[string]interface{}{"d": "deep"},
+	}
+
+	ctx := context.WithValue(context.Background(), contextKey{}, "request")
+	evaluator := newFetchingEvaluator(t, obj, func(ctx context.Context) error {
+		if ctx.Value(contextKey{}) != "request" {
+			return errors.New("context is not passed to the evaluator")
+		}
+		return nil
+	})
+
+	queries := map[string]bool{
+		"a:1 and b:x":                 true,
+		"a:2 or b:y":                  false,
+		"items:{c:2} and n:{d:deep}":  true,
+		"items.c:1":                   false,
+		"n.d:deep":                    true,
+		"deep":                        true,
+		"*.d:deep and not n.d:absent": true,
+	}
+	for query, expected := range queries {
+		expr := mustParse(t, query)
+		res, err := expr.MatchContext(ctx, evaluator)
+		if err != nil || res != expected {
+			t.Errorf("Unexpected result %v, %v for %s. Expected: %v", res, err, query, expected)
+		}
+
+		if res, err := expr.MatchContext(ctx, mustMapEvaluator(t, obj)); err != nil || res != expected {
+			t.Errorf("Unexpected result %v, %v of plain evaluator for %s. Expected: %v", res, err, query, expected)
+		}
+	}
+	if len(*evaluator.fetched) == 0 {
+		t.Error("Expected properties to be fetched with the context")
+	}
+}
+
+func TestMatchContextCancellation(t *testing.T) {
+	obj := map[string]interface{}{"a": 1, "b": 2, "c": 3}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := mustParse(t, "a:1").MatchContext(canceled, mustMapEvaluator(t, obj)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled for a canceled context, got %v", err)
+	}
+
+	for _, query := range []string{"a:0 or b:0 or c:3", "a:1 and b:2 and c:3", "(a:1 and (b:0 or c:3))"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		evaluator := newFetchingEvaluator(t, obj, func(context.Context) error {
+			cancel()
+			return nil
+		})
+
+		_, err := mustParse(t, query).MatchContext(ctx, evaluator)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled for %s, got %v", query, err)
+		}
+		if len(*evaluator.fetched) != 1 {
+			t.Errorf("Expected matching of %s to stop after the first clause, fetched %v", query, *evaluator.fetched)
+		}
+
+		if res, err := mustParse(t, query).Match(evaluator); err != nil || !res {
+			t.Errorf("Unexpected result %v, %v of Match for %s", res, err, query)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	evaluator := newFetchingEvaluator(t, obj, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if _, err := mustParse(t, "a:1").MatchContext(ctx, evaluator); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded from the evaluator, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package gokql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts are the formats accepted for dates in queries, tried in order.
+// Dates without a time zone are in UTC.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// parseDate parses a date in one of dateLayouts or as milliseconds since the Unix epoch.
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(millis).UTC(), nil
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse date %q", value)
+}
+
+// dateMath is an Elasticsearch date math expression: an anchor, which is either now
+// or a date followed by `||`, and a sequence of operations such as `+1M`, `-15m` or `/d`.
+type dateMath struct {
+	anchor     time.Time
+	relative   bool
+	operations []dateMathOperation
+}
+
+type dateMathOperation struct {
+	// op is '+' or '-' to add or subtract amount units, or '/' to round to the unit.
+	op     byte
+	amount int
+	unit   byte
+}
+
+// parseDateMath parses a date, `now` or a date math expression.
+func parseDateMath(value string) (*dateMath, error) {
+	var math dateMath
+	var operations string
+
+	if strings.HasPrefix(value, "now") {
+		math.relative = true
+		operations = value[len("now"):]
+	} else if i := strings.Index(value, "||"); i >= 0 {
+		anchor, err := parseDate(value[:i])
+		if err != nil {
+			return nil, err
+		}
+		math.anchor = anchor
+		operations = value[i+len("||"):]
+	} else {
+		anchor, err := parseDate(value)
+		if err != nil {
+			return nil, err
+		}
+		math.anchor = anchor
+	}
+
+	for len(operations) > 0 {
+		operation := dateMathOperation{op: operations[0]}
+		operations = operations[1:]
+
+		switch operation.op {
+		case '+', '-':
+			digits := len(operations) - len(strings.TrimLeft(operations, "0123456789"))
+			amount, err := strconv.Atoi(operations[:digits])
+			if err != nil {
+				return nil, fmt.Errorf("invalid date math %q: missing amount", value)
+			}
+			operation.amount = amount
+			operations = operations[digits:]
+		case '/':
+		default:
+			return nil, fmt.Errorf("invalid date math %q: unexpected %q", value, operation.op)
+		}
+
+		if len(operations) == 0 || !strings.ContainsRune("yMwdhHms", rune(operations[0])) {
+			return nil, fmt.Errorf("invalid date math %q: missing unit, expected one of y, M, w, d, h, m, s", value)
+		}
+		operation.unit = operations[0]
+		operations = operations[1:]
+
+		math.operations = append(math.operations, operation)
+	}
+
+	return &math, nil
+}
+
+// interval returns the first and the last instant the expression refers to.
+// They differ if the expression is rounded: `now/d` refers to the whole current day.
+func (math *dateMath) interval(now time.Time) (time.Time, time.Time) {
+	anchor := math.anchor
+	if math.relative {
+		anchor = now.UTC()
+	}
+	return math.evaluate(anchor, false), math.evaluate(anchor, true)
+}
+
+func (math *dateMath) evaluate(t time.Time, roundUp bool) time.Time {
+	for _, operation := range math.operations {
+		switch operation.op {
+		case '+':
+			t = addDateUnits(t, operation.amount, operation.unit)
+		case '-':
+			t = addDateUnits(t, -operation.amount, operation.unit)
+		case '/':
+			t = roundDate(t, operation.unit)
+			if roundUp {
+				t = addDateUnits(t, 1, operation.unit).Add(-time.Nanosecond)
+			}
+		}
+	}
+	return t
+}
+
+func addDateUnits(t time.Time, amount int, unit byte) time.Time {
+	switch unit {
+	case 'y':
+		return t.AddDate(amount, 0, 0)
+	case 'M':
+		return t.AddDate(0, amount, 0)
+	case 'w':
+		return t.AddDate(0, 0, 7*amount)
+	case 'd':
+		return t.AddDate(0, 0, amount)
+	case 'h', 'H':
+		return t.Add(time.Duration(amount) * time.Hour)
+	case 'm':
+		return t.Add(time.Duration(amount) * time.Minute)
+	default:
+		return t.Add(time.Duration(amount) * time.Second)
+	}
+}
+
+// roundDate rounds the time down to the beginning of the unit. Weeks begin on Monday.
+func roundDate(t time.Time, unit byte) time.Time {
+	year, month, day := t.Date()
+	switch unit {
+	case 'y':
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, t.Location())
+	case 'M':
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	case 'w':
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, t.Location())
+	case 'd':
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	case 'h', 'H':
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, t.Location())
+	case 'm':
+		return time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, t.Location())
+	default:
+		return time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+	}
+}
@@ -0,0 +1,128 @@
+package gokql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	dates := map[string]time.Time{
+		"2024-03-05T10:20:30Z":           time.Date(2024, 3, 5, 10, 20, 30, 0, time.UTC),
+		"2024-03-05T10:20:30.5+02:00":    time.Date(2024, 3, 5, 8, 20, 30, 500000000, time.UTC),
+		"2024-03-05T10:20:30":            time.Date(2024, 3, 5, 10, 20, 30, 0, time.UTC),
+		"2024-03-05 10:20:30":            time.Date(2024, 3, 5, 10, 20, 30, 0, time.UTC),
+		"2024-03-05T10:20":               time.Date(2024, 3, 5, 10, 20, 0, 0, time.UTC),
+		"2024-03-05":                     time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+		"2024-03":                        time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		"2024":                           time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		"1709634030000":                  time.Date(2024, 3, 5, 10, 20, 30, 0, time.UTC),
+		"2024-03-05T10:20:30.123456789Z": time.Date(2024, 3, 5, 10, 20, 30, 123456789, time.UTC),
+	}
+
+	for value, expected := range dates {
+		actual, err := parseDate(value)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", value, err)
+			continue
+		}
+		if !actual.Equal(expected) {
+			t.Errorf("Unexpected date %v for %s. Expected: %v", actual, value, expected)
+		}
+	}
+
+	for _, value := range []string{"", "yesterday", "2024-13-01", "05.03.2024"} {
+		if _, err := parseDate(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestDateMath(t *testing.T) {
+	// Wednesday
+	now := time.Date(2024, 3, 6, 10, 20, 30, 0, time.UTC)
+
+	testInterval := func(value string, expectedStart time.Time, expectedEnd time.Time) {
+		t.Helper()
+		math, err := parseDateMath(value)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", value, err)
+		}
+
+		start, end := math.interval(now)
+		if !start.Equal(expectedStart) || !end.Equal(expectedEnd) {
+			t.Errorf("Unexpected interval [%v, %v] for %s. Expected: [%v, %v]", start, end, value, expectedStart, expectedEnd)
+		}
+	}
+	testPoint := func(value string, expected time.Time) {
+		t.Helper()
+		testInterval(value, expected, expected)
+	}
+	endOf := func(next time.Time) time.Time {
+		return next.Add(-time.Nanosecond)
+	}
+
+	testPoint("now", now)
+	testPoint("now-15m", now.Add(-15*time.Minute))
+	testPoint("now+1h-30s", now.Add(time.Hour-30*time.Second))
+	testPoint("now-1y", time.Date(2023, 3, 6, 10, 20, 30, 0, time.UTC))
+	testPoint("now+2w", time.Date(2024, 3, 20, 10, 20, 30, 0, time.UTC))
+	testPoint("2024-01-31||+1M", time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+	testPoint("2024-01-01", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	testInterval("now/d", time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC), endOf(time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)))
+	testInterval("now-1d/d", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), endOf(time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)))
+	testInterval("now/w", time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), endOf(time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)))
+	testInterval("now/M", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), endOf(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)))
+	testInterval("now/y", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), endOf(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+	testInterval("now/h", time.Date(2024, 3, 6, 10, 0, 0, 0, time.UTC), endOf(time.Date(2024, 3, 6, 11, 0, 0, 0, time.UTC)))
+	testInterval("2024-01-15T12:00:00Z||/M", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), endOf(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)))
+
+	for _, value := range []string{"now-", "now-15", "now-15x", "now*2d", "now/", "nope||+1d", "2024-01-01||1d"} {
+		if _, err := parseDateMath(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestDateMathMatch(t *testing.T) {
+	now := time.Date(2024, 3, 6, 10, 20, 30, 0, time.UTC)
+	options := ParseOptions{Clock: func() time.Time { return now }}
+	obj := map[string]interface{}{
+		"@timestamp": now.Add(-10 * time.Minute),
+		"created":    time.Date(2024, 3, 5, 23, 0, 0, 0, time.UTC),
+	}
+
+	testExprWithOptions(t, "@timestamp >= now-15m", options, obj, true)
+	testExprWithOptions(t, "@timestamp >= now-5m", options, obj, false)
+	testExprWithOptions(t, "@timestamp:now/d", options, obj, true)
+	testExprWithOptions(t, "created < now/d", options, obj, true)
+	testExprWithOptions(t, "created:now-1d/d", options, obj, true)
+	testExprWithOptions(t, "created > now-1d/d", options, obj, false)
+	testExprWithOptions(t, "created <= now-1d/d", options, obj, true)
+	testExprWithOptions(t, "created >= now-1d/d", options, obj, true)
+	testExprWithOptions(t, `created > "2024-01-01||+1M"`, options, obj, true)
+	testExprWithOptions(t, `created < "2024-03-05||+1d"`, options, obj, true)
+	testExprWithOptions(t, "created > 2024-03-05", options, obj, true)
+	testExprWithOptions(t, "created:'2024-03-05T23:00:00'", options, obj, true)
+	testExprWithOptions(t, "created:1709679600000", options, obj, true)
+	testExprWithOptions(t, "created:(now/d or now-1d/d)", options, obj, true)
+
+	// the clock is read on every match, not when the comparer is created
+	expr, err := ParseWithOptions("@timestamp >= now-15m", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluator, _ := NewMapEvaluator(obj)
+	if res, err := expr.Match(evaluator); err != nil || !res {
+		t.Errorf("Unexpected match result: %v, %v", res, err)
+	}
+	now = now.Add(time.Hour)
+	if res, err := expr.Match(evaluator); err != nil || res {
+		t.Errorf("Unexpected match result after the clock moved: %v, %v", res, err)
+	}
+
+	expr = mustParse(t, "created > 'now-1x'")
+	if _, err := expr.Match(evaluator); err == nil {
+		t.Error("Expected error for invalid date math")
+	}
+}
@@ -0,0 +1,368 @@
+package gokql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ElasticsearchQuery translates the expression into Elasticsearch/OpenSearch query DSL.
+// The result can be marshalled to JSON and used as the "query" part of a search request.
+//
+// Dotted property names are used as field paths, `{}` sub-expressions become nested queries,
+// `:` becomes a term, wildcard, regexp or exists query and range operations become range queries.
+// Field-less terms become multi_match queries over the default fields of the expression.
+// Matches of field name patterns become query_string queries, in which Elasticsearch expands
+// the pattern against the fields of the index; nested queries on field name patterns are reported as errors.
+func (expression Expression) ElasticsearchQuery() (map[string]interface{}, error) {
+	if expression.ast == nil {
+		return map[string]interface{}{"match_all": map[string]interface{}{}}, nil
+	}
+
+	translator := elasticTranslator{defaultFields: expression.options.DefaultFields}
+	query := translator.expression(expression.ast, nil)
+	if translator.err != nil {
+		return nil, translator.err
+	}
+	return query, nil
+}
+
+type elasticTranslator struct {
+	defaultFields []string
+	// err is the first error of the translation
+	err error
+}
+
+func (t *elasticTranslator) expression(expr *expression, path []string) map[string]interface{} {
+	return t.disjunction(expr.Expr, path)
+}
+
+func (t *elasticTranslator) disjunction(d disjunction, path []string) map[string]interface{} {
+	if len(d.RightValues) == 0 {
+		return t.conjunction(d.LeftValue, path)
+	}
+
+	should := []interface{}{t.conjunction(d.LeftValue, path)}
+	for _, right := range d.RightValues {
+		should = append(should, t.conjunction(right, path))
+	}
+
+	return elasticBool("should", should)
+}
+
+func (t *elasticTranslator) conjunction(c conjunction, path []string) map[string]interface{} {
+	if len(c.RightValues) == 0 {
+		return t.subExpression(c.LeftValue, path)
+	}
+
+	filter := []interface{}{t.subExpression(c.LeftValue, path)}
+	for _, right := range c.RightValues {
+		filter = append(filter, t.subExpression(right, path))
+	}
+
+	return elasticBool("filter", filter)
+}
+
+func (t *elasticTranslator) subExpression(se subExpression, path []string) map[string]interface{} {
+	var query map[string]interface{}
+	if se.SubExpression != nil {
+		query = t.expression(se.SubExpression, path)
+	} else if se.FreeText != nil {
+		query = t.freeText(se.FreeText)
+	} else {
+		query = t.propertyMatch(se.Value, path)
+	}
+
+	if se.IsInverted {
+		return elasticBool("must_not", []interface{}{query})
+	}
+
+	return query
+}
+
+func (t *elasticTranslator) propertyMatch(prop *propertyMatch, path []string) map[string]interface{} {
+	if prop.pattern != nil {
+		if prop.ValueSubExpression != nil && t.err == nil {
+			t.err = fmt.Errorf("nested query on field name pattern %s is not supported in Elasticsearch", strings.Join(prop.Name, "."))
+		}
+		return elasticFieldPattern(path, prop)
+	}
+
+	fieldPath := append(append([]string{}, path...), prop.Name...)
+	field := strings.Join(fieldPath, ".")
+
+	if prop.ValueSubExpression != nil {
+		return map[string]interface{}{
+			"nested": map[string]interface{}{
+				"path":  field,
+				"query": t.expression(prop.ValueSubExpression, fieldPath),
+			},
+		}
+	}
+
+	if prop.AtomicValue != nil {
+		return elasticValue(field, prop.Operation, prop.AtomicValue)
+	}
+
+	return elasticValueDisjunction(field, prop.Operation, prop.ValueList)
+}
+
+// elasticFieldPattern translates a match of a field name pattern into a query_string query.
+func elasticFieldPattern(path []string, prop *propertyMatch) map[string]interface{} {
+	field := prop.pattern.wildcard.format(`\*`, escapeQueryString)
+	if prefix := append(append([]string{}, path...), prop.pattern.prefix...); len(prefix) > 0 {
+		field = escapeQueryString(strings.Join(prefix, ".")) + "." + field
+	}
+
+	var value string
+	if prop.AtomicValue != nil {
+		value = queryStringValue(prop.Operation, prop.AtomicValue)
+	} else if prop.ValueList != nil {
+		value = queryStringDisjunction(prop.Operation, prop.ValueList)
+	}
+
+	return map[string]interface{}{
+		"query_string": map[string]interface{}{"query": field + ":" + value},
+	}
+}
+
+func queryStringDisjunction(operation string, d *valueDisjunction) string {
+	values := []string{queryStringConjunction(operation, d.LeftValue)}
+	for _, right := range d.RightValues {
+		values = append(values, queryStringConjunction(operation, right))
+	}
+	if len(values) == 1 {
+		return values[0]
+	}
+	return "(" + strings.Join(values, " OR ") + ")"
+}
+
+func queryStringConjunction(operation string, c valueConjunction) string {
+	values := []string{queryStringTerm(operation, c.LeftValue)}
+	for _, right := range c.RightValues {
+		values = append(values, queryStringTerm(operation, right))
+	}
+	if len(values) == 1 {
+		return values[0]
+	}
+	return "(" + strings.Join(values, " AND ") + ")"
+}
+
+func queryStringTerm(operation string, v valueTerm) string {
+	var value string
+	if v.ValueList != nil {
+		value = queryStringDisjunction(operation, v.ValueList)
+	} else {
+		value = queryStringValue(operation, v.Value)
+	}
+
+	if v.IsInverted {
+		return "(NOT " + value + ")"
+	}
+	return value
+}
+
+// queryStringValue formats a value in the query_string syntax: ranges as `>=value`, existence as `*`,
+// wildcards with their stars and other values as quoted phrases.
+func queryStringValue(operation string, atomic *atomicValue) string {
+	switch {
+	case operation != ":":
+		return operation + escapeQueryString(atomic.Value)
+	case atomic.regexp != nil:
+		return formatRegexp(atomic.Value)
+	case atomic.isExistence():
+		return "*"
+	case atomic.wildcard.isPattern():
+		return atomic.wildcard.format("*", escapeQueryString)
+	default:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(atomic.Value) + `"`
+	}
+}
+
+func elasticValueDisjunction(field string, operation string, d *valueDisjunction) map[string]interface{} {
+	if len(d.RightValues) == 0 {
+		return elasticValueConjunction(field, operation, d.LeftValue)
+	}
+
+	should := []interface{}{elasticValueConjunction(field, operation, d.LeftValue)}
+	for _, right := range d.RightValues {
+		should = append(should, elasticValueConjunction(field, operation, right))
+	}
+
+	return elasticBool("should", should)
+}
+
+func elasticValueConjunction(field string, operation string, c valueConjunction) map[string]interface{} {
+	if len(c.RightValues) == 0 {
+		return elasticValueTerm(field, operation, c.LeftValue)
+	}
+
+	filter := []interface{}{elasticValueTerm(field, operation, c.LeftValue)}
+	for _, right := range c.RightValues {
+		filter = append(filter, elasticValueTerm(field, operation, right))
+	}
+
+	return elasticBool("filter", filter)
+}
+
+func elasticValueTerm(field string, operation string, v valueTerm) map[string]interface{} {
+	var query map[string]interface{}
+	if v.ValueList != nil {
+		query = elasticValueDisjunction(field, operation, v.ValueList)
+	} else {
+		query = elasticValue(field, operation, v.Value)
+	}
+
+	if v.IsInverted {
+		return elasticBool("must_not", []interface{}{query})
+	}
+
+	return query
+}
+
+func elasticValue(field string, operation string, atomic *atomicValue) map[string]interface{} {
+	if operation == ":" {
+		return elasticTerm(field, atomic)
+	}
+	return elasticRange(field, operation, atomic)
+}
+
+func (t *elasticTranslator) freeText(atomic *atomicValue) map[string]interface{} {
+	if atomic.wildcard.matchesAll() {
+		return map[string]interface{}{"match_all": map[string]interface{}{}}
+	}
+
+	queryString := atomic.wildcard.isPattern() || atomic.regexp != nil
+	var query map[string]interface{}
+	if queryString {
+		query = map[string]interface{}{
+			"query": queryStringValue(":", atomic),
+		}
+	} else {
+		query = map[string]interface{}{
+			"query":   atomic.Value,
+			"type":    "best_fields",
+			"lenient": true,
+		}
+		if atomic.quoted {
+			query["type"] = "phrase"
+		}
+	}
+
+	if len(t.defaultFields) > 0 {
+		fields := make([]interface{}, len(t.defaultFields))
+		for i, field := range t.defaultFields {
+			fields[i] = field
+		}
+		query["fields"] = fields
+	}
+
+	if queryString {
+		return map[string]interface{}{"query_string": query}
+	}
+
+	return map[string]interface{}{"multi_match": query}
+}
+
+func elasticBool(occur string, queries []interface{}) map[string]interface{} {
+	boolQuery := map[string]interface{}{
+		occur: queries,
+	}
+	if occur == "should" {
+		boolQuery["minimum_should_match"] = 1
+	}
+
+	return map[string]interface{}{"bool": boolQuery}
+}
+
+func elasticTerm(field string, atomic *atomicValue) map[string]interface{} {
+	if atomic.isExistence() {
+		return map[string]interface{}{
+			"exists": map[string]interface{}{"field": field},
+		}
+	}
+
+	if atomic.regexp != nil {
+		return map[string]interface{}{
+			"regexp": map[string]interface{}{
+				field: map[string]interface{}{"value": atomic.Value},
+			},
+		}
+	}
+
+	text := atomic.comparers.fieldSchema().Type == FieldText
+	if text && atomic.quoted {
+		return map[string]interface{}{
+			"match_phrase": map[string]interface{}{
+				field: map[string]interface{}{"query": atomic.Value},
+			},
+		}
+	}
+
+	if atomic.wildcard.isPattern() {
+		return map[string]interface{}{
+			"wildcard": map[string]interface{}{
+				field: map[string]interface{}{"value": atomic.wildcard.format("*", escapeWildcard)},
+			},
+		}
+	}
+
+	if text {
+		return map[string]interface{}{
+			"match": map[string]interface{}{
+				field: map[string]interface{}{"query": atomic.Value},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"term": map[string]interface{}{
+			field: map[string]interface{}{"value": atomic.Value},
+		},
+	}
+}
+
+func elasticRange(field string, operation string, atomic *atomicValue) map[string]interface{} {
+	var bound string
+	switch operation {
+	case ">":
+		bound = "gt"
+	case ">=":
+		bound = "gte"
+	case "<":
+		bound = "lt"
+	case "<=":
+		bound = "lte"
+	default:
+		panic("unknown operation " + operation)
+	}
+
+	return map[string]interface{}{
+		"range": map[string]interface{}{
+			field: map[string]interface{}{bound: atomic.Value},
+		},
+	}
+}
+
+// escapeWildcard escapes characters which have a special meaning in wildcard queries.
+func escapeWildcard(value string) string {
+	var builder strings.Builder
+	for _, r := range value {
+		if r == '?' || r == '*' || r == '\\' {
+			builder.WriteByte('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// escapeQueryString escapes reserved characters of the query_string syntax.
+func escapeQueryString(value string) string {
+	var builder strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune(`+-=&|><!(){}[]^"~*?:\/ `, r) {
+			builder.WriteByte('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
@@ -0,0 +1,66 @@
+package gokql
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+func TestElasticsearchQuery(t *testing.T) {
+	testGolden := func(name string, query string, options ParseOptions) {
+		t.Helper()
+		expr, err := ParseWithOptions(query, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		esQuery, err := expr.ElasticsearchQuery()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := json.MarshalIndent(esQuery, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual = append(actual, '\n')
+
+		goldenPath := filepath.Join("testdata", "elastic", name+".json")
+		if *updateGolden {
+			if err := os.WriteFile(goldenPath, actual, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expected, err := os.ReadFile(goldenPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("Unexpected query for %s:\n%s\nExpected:\n%s", query, actual, expected)
+		}
+	}
+
+	testGolden("term", "status:200", ParseOptions{})
+	testGolden("dotted", "host.name:'web'", ParseOptions{})
+	testGolden("range", "a>0 and b>=1 and c<2 and d<=3", ParseOptions{})
+	testGolden("or_and_not", "a:1 or b:2 and not c:3", ParseOptions{})
+	testGolden("wildcard", `name:web* and path:*a\?b* and tag:\** and note:'x*'`, ParseOptions{})
+	testGolden("exists", "name:* and not other:*", ParseOptions{})
+	testGolden("value_lists", "a:(1 or 2*) and b:(x and y)", ParseOptions{})
+	testGolden("value_lists_nested", "status:(200 or (3* and not 304)) and ratio>=(0.5 or 1)", ParseOptions{})
+	testGolden("text", `message:"connection refused" and message:timeout and message:conn* and level:error`,
+		ParseOptions{Schema: Schema{"message": {Type: FieldText}}})
+	testGolden("nested", "items:{name:a and tags:{value:b}}", ParseOptions{})
+	testGolden("field_pattern", `machine.os*:windows and *.status>=500 and host.*:(web* or "a b") and items:{*:* and not x*:1}`, ParseOptions{})
+	testGolden("regexp", `path:/\/api\/v[12]\/.*/ and tags:(/web-\d+/ or db) and h*:/x.y/ and /err.*/`, ParseOptions{})
+	testGolden("free_text", "error or conn*", ParseOptions{})
+	testGolden("free_text_default_fields", "error", ParseOptions{DefaultFields: []string{"message", "host.name"}})
+	testGolden("free_text_phrase", `"connection refused" and not 'x*'`, ParseOptions{DefaultFields: []string{"message"}})
+}
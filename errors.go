@@ -0,0 +1,217 @@
+package gokql
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/participle"
+)
+
+// ParseError describes a syntax error in a query.
+type ParseError struct {
+	// Query is the query which failed to parse.
+	Query string
+	// Offset is the byte offset of the error in the query.
+	Offset int
+	// Line and Column are the 1-based position of the error in the query.
+	Line   int
+	Column int
+	// Token is the text of the offending token. It is empty if the query ended unexpectedly.
+	Token string
+	// Expected lists alternatives which were expected at the position of the error.
+	Expected []string
+	// Message describes the error.
+	Message string
+	// Hint suggests how to fix a common mistake. It is empty if no suggestion is known.
+	Hint string
+
+	err error
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+	if e.Hint != "" {
+		msg += " (" + e.Hint + ")"
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.err
+}
+
+// Caret renders the line of the query containing the error with a caret under the error position:
+//
+//	status=200
+//	      ^
+func (e *ParseError) Caret() string {
+	lineStart := strings.LastIndexByte(e.Query[:e.Offset], '\n') + 1
+	lineEnd := strings.IndexByte(e.Query[e.Offset:], '\n')
+	if lineEnd == -1 {
+		lineEnd = len(e.Query)
+	} else {
+		lineEnd += e.Offset
+	}
+
+	line := e.Query[lineStart:lineEnd]
+	padding := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return '\t'
+		}
+		return ' '
+	}, e.Query[lineStart:e.Offset])
+
+	return line + "\n" + padding + "^"
+}
+
+func newParseError(query string, err error) *ParseError {
+	parseError := &ParseError{
+		Query:   query,
+		Line:    1,
+		Column:  1,
+		Message: err.Error(),
+		err:     err,
+	}
+
+	perr, ok := err.(participle.Error)
+	if !ok {
+		return parseError
+	}
+
+	token := perr.Token()
+	parseError.Message = perr.Message()
+	parseError.Offset = token.Pos.Offset
+	if parseError.Offset < 0 || parseError.Offset > len(query) {
+		parseError.Offset = len(query)
+	}
+	if !token.EOF() {
+		parseError.Token = token.Value
+	}
+	parseError.Line, parseError.Column = position(query, parseError.Offset)
+
+	if unexpected, ok := err.(participle.UnexpectedTokenError); ok {
+		parseError.Expected = expectedAlternatives(unexpected.Expected)
+	}
+	parseError.Hint = hint(parseError)
+
+	return parseError
+}
+
+// newValueError reports a value which is syntactically valid but cannot be used, such as
+// an invalid regular expression, at the position of the value.
+func newValueError(query string, offset int, token string, err error) *ParseError {
+	if offset < 0 || offset > len(query) {
+		offset = 0
+	}
+	line, column := position(query, offset)
+	return &ParseError{
+		Query:   query,
+		Offset:  offset,
+		Line:    line,
+		Column:  column,
+		Token:   token,
+		Message: err.Error(),
+		err:     err,
+	}
+}
+
+func position(query string, offset int) (line int, column int) {
+	prefix := query[:offset]
+	lineStart := strings.LastIndexByte(prefix, '\n') + 1
+	return strings.Count(prefix, "\n") + 1, utf8.RuneCountInString(prefix[lineStart:]) + 1
+}
+
+func expectedAlternatives(expected string) []string {
+	if expected == "" {
+		return nil
+	}
+
+	var result []string
+	seen := map[string]bool{}
+	for _, alternative := range strings.Split(expected, " | ") {
+		switch alternative {
+		case "<literal>", "<ipaddress>", "<datemath>", "<number>":
+			alternative = "value"
+		case "<quotedstring>", "<dquotedstring>":
+			alternative = "quoted string"
+		case "<regexp>":
+			alternative = "regular expression"
+		default:
+			alternative = strings.Trim(alternative, `"`)
+		}
+
+		if !seen[alternative] {
+			seen[alternative] = true
+			result = append(result, alternative)
+		}
+	}
+	return result
+}
+
+func hint(e *ParseError) string {
+	switch e.Token {
+	case "=":
+		return `use ":" instead of "=" to compare a field with a value`
+	case "&":
+		return `use "and" instead of "&&"`
+	case "|":
+		return `use "or" instead of "||"`
+	case ".":
+		return `values containing dots must be quoted or escaped, for example host:"web.local" or host:web\.local`
+	case "'", `"`:
+		return "quoted string is not terminated"
+	case "/":
+		return `regular expression is not terminated, values containing slashes must be quoted`
+	}
+
+	if opening, closing := countUnquoted(e.Query, '('), countUnquoted(e.Query, ')'); opening != closing {
+		if opening > closing {
+			return `unbalanced parentheses: missing ")"`
+		}
+		return `unbalanced parentheses: unexpected ")"`
+	}
+
+	if opening, closing := countUnquoted(e.Query, '{'), countUnquoted(e.Query, '}'); opening != closing {
+		if opening > closing {
+			return `unbalanced braces: missing "}"`
+		}
+		return `unbalanced braces: unexpected "}"`
+	}
+
+	if e.Token != "" && len(e.Expected) == 0 && isLiteral(e.Token) {
+		return `use "and" or "or" to combine conditions`
+	}
+
+	return ""
+}
+
+// countUnquoted counts occurrences of a character outside of quoted strings which are not escaped with a backslash.
+func countUnquoted(query string, char byte) int {
+	count := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		switch {
+		case query[i] == '\\':
+			i++
+		case quote != 0:
+			if query[i] == quote {
+				quote = 0
+			}
+		case query[i] == '\'' || query[i] == '"':
+			quote = query[i]
+		case query[i] == char:
+			count++
+		}
+	}
+	return count
+}
+
+func isLiteral(token string) bool {
+	for _, r := range token {
+		if !isLiteralChar(r) && r != '@' {
+			return false
+		}
+	}
+	return true
+}
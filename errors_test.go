@@ -0,0 +1,78 @@
+package gokql
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	testParseError := func(query string, line int, column int, token string, hint string) *ParseError {
+		t.Helper()
+		_, err := Parse(query)
+		var parseError *ParseError
+		if !errors.As(err, &parseError) {
+			t.Fatalf("Expected *ParseError for %q, got %T: %v", query, err, err)
+		}
+
+		if parseError.Line != line || parseError.Column != column {
+			t.Errorf("Unexpected position %d:%d for %q. Expected: %d:%d", parseError.Line, parseError.Column, query, line, column)
+		}
+		if parseError.Token != token {
+			t.Errorf("Unexpected token %q for %q. Expected: %q", parseError.Token, query, token)
+		}
+		if !strings.Contains(parseError.Hint, hint) {
+			t.Errorf("Unexpected hint %q for %q. Expected to contain: %q", parseError.Hint, query, hint)
+		}
+		return parseError
+	}
+
+	testParseError("status=200", 1, 7, "=", `":" instead of "="`)
+	testParseError("a:1 && b:2", 1, 5, "&", `"and"`)
+	testParseError("a:1 || b:2", 1, 5, "|", `"or"`)
+	testParseError("(a:1 or b:2", 1, 12, "", `missing ")"`)
+	testParseError("a:1)", 1, 4, ")", `unexpected ")"`)
+	testParseError("a:{b:1", 1, 7, "", `missing "}"`)
+	dotHint := testParseError("host:web.example.com", 1, 9, ".", "must be quoted").Hint
+	for _, suggestion := range []string{`host:"web.local"`, `host:web\.local`} {
+		if !strings.Contains(dotHint, suggestion) {
+			t.Errorf("Expected hint %q to suggest %s", dotHint, suggestion)
+		}
+		if _, err := Parse(suggestion); err != nil {
+			t.Errorf("Suggested query %s doesn't parse: %v", suggestion, err)
+		}
+	}
+	testParseError("a:'x", 1, 3, "'", "not terminated")
+	testParseError("a:b c:d", 1, 5, "c", `"and" or "or"`)
+	testParseError(`a:\( b:1`, 1, 6, "b", `"and" or "or"`)
+	testParseError(`a:'it\'s (' b:1`, 1, 13, "b", `"and" or "or"`)
+	testParseError(`(a:\) or b:1`, 1, 13, "", `missing ")"`)
+	testParseError("a:1 and\nb=2", 2, 2, "=", `":" instead of "="`)
+	testParseError("path:/api", 1, 6, "/", "regular expression is not terminated")
+
+	parseError := testParseError("a:", 1, 3, "", "")
+	if expected := []string{"{", "value", "quoted string", "regular expression", "("}; !reflect.DeepEqual(parseError.Expected, expected) {
+		t.Errorf("Unexpected alternatives: %v. Expected: %v", parseError.Expected, expected)
+	}
+}
+
+func TestParseErrorCaret(t *testing.T) {
+	testCaret := func(query string, expected string) {
+		t.Helper()
+		_, err := Parse(query)
+		var parseError *ParseError
+		if !errors.As(err, &parseError) {
+			t.Fatalf("Expected *ParseError for %q, got %T: %v", query, err, err)
+		}
+
+		if caret := parseError.Caret(); caret != expected {
+			t.Errorf("Unexpected caret rendering:\n%s\nExpected:\n%s", caret, expected)
+		}
+	}
+
+	testCaret("status=200", "status=200\n      ^")
+	testCaret("(a:1", "(a:1\n    ^")
+	testCaret("a:1 and\nb=2", "b=2\n ^")
+	testCaret("a:'ü' and b=2", "a:'ü' and b=2\n           ^")
+}
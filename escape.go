@@ -0,0 +1,163 @@
+package gokql
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Patterns of the lexer tokens which can hold unquoted values.
+const (
+	ipAddressPattern = `\d{1,3}(\.\d{1,3}){3}(/\d{1,2})?`
+	dateMathPattern  = `now([+-]\d+[yMwdhHms]|/[yMwdhHms])+\b`
+	numberPattern    = `[-+]?\d+\.\d+|\+\d+`
+	literalPattern   = `@?(\\.|[-a-zA-Z0-9*_])+`
+)
+
+var (
+	ipAddressRegexp = regexp.MustCompile(`^` + ipAddressPattern + `$`)
+	dateMathRegexp  = regexp.MustCompile(`^` + dateMathPattern + `$`)
+	numberRegexp    = regexp.MustCompile(`^(` + numberPattern + `)$`)
+)
+
+// unescapeLiteral converts an unquoted literal token to the pattern form of its value:
+// a backslash makes the next character literal, and only literal stars and backslashes
+// stay escaped, as `\*` and `\\`, so that they are told apart from wildcards.
+func unescapeLiteral(token string) string {
+	var builder strings.Builder
+	for i := 0; i < len(token); i++ {
+		if token[i] == '\\' && i+1 < len(token) {
+			i++
+			if token[i] == '*' || token[i] == '\\' {
+				builder.WriteByte('\\')
+			}
+		}
+		builder.WriteByte(token[i])
+	}
+	return builder.String()
+}
+
+// unescapePattern converts the pattern form of a value to the value itself,
+// with literal and wildcard stars no longer distinguished.
+func unescapePattern(pattern string) string {
+	if !strings.Contains(pattern, `\`) {
+		return pattern
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		builder.WriteByte(pattern[i])
+	}
+	return builder.String()
+}
+
+// escapePattern converts a value to the pattern form in which every star is literal.
+func escapePattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`).Replace(value)
+}
+
+// unescapeQuoted strips the quotes of a quoted string token. Within the quotes a backslash
+// escapes the quote character or another backslash. Other backslashes are kept as they are.
+func unescapeQuoted(token string) string {
+	if len(token) < 2 {
+		return token
+	}
+
+	quote := token[0]
+	token = token[1 : len(token)-1]
+	if !strings.Contains(token, `\`) {
+		return token
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(token); i++ {
+		if token[i] == '\\' && i+1 < len(token) && (token[i+1] == quote || token[i+1] == '\\') {
+			i++
+		}
+		builder.WriteByte(token[i])
+	}
+	return builder.String()
+}
+
+// quoteValue formats a value as a double quoted string.
+func quoteValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// formatPattern formats the pattern form of an unquoted value so that it is parsed back
+// to the same value: characters which cannot appear in an unquoted literal are escaped.
+func formatPattern(pattern string) string {
+	if ipAddressRegexp.MatchString(pattern) || dateMathRegexp.MatchString(pattern) || numberRegexp.MatchString(pattern) {
+		return pattern
+	}
+	if pattern == "or" || pattern == "and" || pattern == "not" {
+		return `\` + pattern
+	}
+	return escapeLiteral(pattern)
+}
+
+// formatRegexp formats the pattern of a regular expression as a `/pattern/` value,
+// escaping the slashes which would end it.
+func formatRegexp(pattern string) string {
+	var builder strings.Builder
+	builder.WriteByte('/')
+	escaped := false
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '/' && !escaped {
+			builder.WriteByte('\\')
+		}
+		escaped = pattern[i] == '\\' && !escaped
+		builder.WriteByte(pattern[i])
+	}
+	builder.WriteByte('/')
+	return builder.String()
+}
+
+// anchorRegexp makes a regular expression match whole strings only.
+func anchorRegexp(pattern string) string {
+	return `^(?:` + pattern + `)$`
+}
+
+// formatFieldName formats the parts of a property name so that it is parsed back to the same parts.
+func formatFieldName(field []string) string {
+	parts := make([]string, len(field))
+	for i, part := range field {
+		parts[i] = escapePattern(part)
+	}
+	return formatFieldPattern(parts)
+}
+
+// formatFieldPattern formats the parts of a property name in pattern form, keeping their wildcards.
+func formatFieldPattern(field []string) string {
+	parts := make([]string, len(field))
+	for i, part := range field {
+		parts[i] = escapeLiteral(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// escapeLiteral escapes the characters of a pattern which are not allowed in a literal.
+// Escape sequences of the pattern are kept.
+func escapeLiteral(pattern string) string {
+	var builder strings.Builder
+	escaped := false
+	for i, r := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && i+1 < len(pattern):
+			escaped = true
+		case r == '@' && i == 0, isLiteralChar(r):
+		default:
+			builder.WriteByte('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+func isLiteralChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '*' || r == '-'
+}
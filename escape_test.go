@@ -0,0 +1,108 @@
+package gokql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEscapes(t *testing.T) {
+	obj := map[string]interface{}{
+		"file":      "report*.pdf",
+		"title":     "a (b): c",
+		"path":      `C:\temp`,
+		"quote":     `say "hi"`,
+		"keyword":   "or",
+		"dotted.id": "x",
+		"tags":      []string{"a b", "c*"},
+	}
+
+	testExprMap(t, `file:report\*`, obj, false)
+	testExprMap(t, `file:report\**`, obj, true)
+	testExprMap(t, `file:*\*\.pdf`, obj, true)
+	testExprMap(t, `file:"report*.pdf"`, obj, true)
+	testExprMap(t, `file:"report*"`, obj, false)
+	testExprMap(t, `title:a\ \(b\)\:\ c`, obj, true)
+	testExprMap(t, `title:a\ \(b*`, obj, true)
+	testExprMap(t, `path:C\:\\temp`, obj, true)
+	testExprMap(t, `path:'C:\temp'`, obj, true)
+	testExprMap(t, `path:'C:\\temp'`, obj, true)
+	testExprMap(t, `quote:"say \"hi\""`, obj, true)
+	testExprMap(t, `quote:'say "hi"'`, obj, true)
+	testExprMap(t, `keyword:\or`, obj, true)
+	testExprMap(t, `dotted\.id:x`, obj, true)
+	testExprMap(t, `tags:a\ b and tags:c\*`, obj, true)
+	testExprMap(t, `tags:"c*" and not tags:"c"`, obj, true)
+	testExprMap(t, `a\ b`, obj, true)
+
+	expr := mustParse(t, `dotted\.id:x`)
+	if fields := expr.Fields(); !reflect.DeepEqual(fields, []string{"dotted.id"}) {
+		t.Errorf("Unexpected fields: %q", fields)
+	}
+}
+
+func TestEscapesRoundTrip(t *testing.T) {
+	queries := map[string]string{
+		`file:report\**`:               `file:report\**`,
+		`file:"report*"`:               `file:"report*"`,
+		`file:'report*'`:               `file:"report*"`,
+		`title:a\ \(b\)\:\ c`:          `title:a\ \(b\)\:\ c`,
+		`path:C\:\\temp`:               `path:C\:\\temp`,
+		`path:'C:\temp'`:               `path:"C:\\temp"`,
+		`quote:"say \"hi\""`:           `quote:"say \"hi\""`,
+		`quote:'it\'s'`:                `quote:"it's"`,
+		`keyword:\or`:                  `keyword:\or`,
+		`key\word:\x`:                  `keyword:x`,
+		`dotted\.id:x and a\ b:\@c`:    `(dotted\.id:x and a\ b:@c)`,
+		`@timestamp:@now`:              `@timestamp:@now`,
+		`ip:10.0.0.0/8 and d<now-1d`:   `(ip:10.0.0.0/8 and d<now-1d)`,
+		`ratio:(0.5 or +1) and a<-1.5`: `(ratio:(0.5 or +1) and a<-1.5)`,
+		`tags:(a\ b or "c*" or c\*)`:   `tags:(a\ b or "c*" or c\*)`,
+		`n:{x\.y:(\* or *)}`:           `n:{x\.y:(\* or *)}`,
+		`caf\` + "\u00e9" + `\ bar*`:   `caf\` + "\u00e9" + `\ bar*`,
+	}
+
+	for query, expected := range queries {
+		expr := mustParse(t, query)
+		if actual := expr.String(); actual != expected {
+			t.Errorf("Unexpected string %s for %s. Expected: %s", actual, query, expected)
+		}
+		if actual := expr.Root().String(); actual != expected {
+			t.Errorf("Unexpected node string %s for %s. Expected: %s", actual, query, expected)
+		}
+
+		reparsed := mustParse(t, expr.String())
+		if !reflect.DeepEqual(reparsed.Root(), expr.Root()) {
+			t.Errorf("Reparsed %s differs from %s", reparsed.String(), query)
+		}
+
+		rebuilt, err := NewExpression(expr.Root(), ParseOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rebuilt.String() != expected {
+			t.Errorf("Unexpected rebuilt string %s for %s. Expected: %s", rebuilt.String(), query, expected)
+		}
+	}
+
+	values := []*ValueNode{
+		{Text: "1.5"},
+		{Text: "a b"},
+		{Text: `x\*y*`},
+		{Text: `trailing\`},
+		{Text: "and"},
+		{Text: `a*"b`, Quoted: true},
+	}
+	for _, value := range values {
+		expr, err := NewExpression(&MatchNode{Field: []string{"f.g", "h"}, Operator: ":", Value: value}, ParseOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		reparsed, err := Parse(expr.String())
+		if err != nil {
+			t.Fatalf("Unable to parse %s: %v", expr.String(), err)
+		}
+		if !reflect.DeepEqual(reparsed.Root(), expr.Root()) {
+			t.Errorf("Reparsed %s differs from %#v", reparsed.String(), value)
+		}
+	}
+}
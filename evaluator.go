@@ -1,8 +1,12 @@
 package gokql
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 type EvaluatorKind string
@@ -19,6 +23,72 @@ type Evaluator interface {
 	GetArraySubEvaluators() ([]Evaluator, error)
 }
 
+// KeysEvaluator is an optional interface implemented by evaluators which can enumerate
+// names of their properties. It is used to match field-less terms against all fields.
+type KeysEvaluator interface {
+	Keys() ([]string, error)
+}
+
+// ContextEvaluator is an optional interface implemented by evaluators whose properties are costly
+// to get, for example lazily fetched from a cache or a database. Expression.MatchContext gets
+// properties of such evaluators with EvaluateContext, so that fetching them can be cancelled,
+// while Match uses Evaluate. Sub evaluators implementing the interface get the context too.
+type ContextEvaluator interface {
+	Evaluator
+	EvaluateContext(ctx context.Context, propertyName string) (interface{}, error)
+}
+
+// withContext adapts an evaluator to the Evaluator interface used by matching, binding the context
+// to the properties of a ContextEvaluator. Other evaluators are returned as they are.
+func withContext(ctx context.Context, evaluator Evaluator) Evaluator {
+	if contextEvaluator, ok := evaluator.(ContextEvaluator); ok {
+		return &contextBoundEvaluator{ctx, contextEvaluator}
+	}
+	return evaluator
+}
+
+// contextBoundEvaluator evaluates properties of a ContextEvaluator with a context.
+type contextBoundEvaluator struct {
+	ctx       context.Context
+	evaluator ContextEvaluator
+}
+
+func (e *contextBoundEvaluator) Evaluate(propertyName string) (interface{}, error) {
+	return e.evaluator.EvaluateContext(e.ctx, propertyName)
+}
+
+func (e *contextBoundEvaluator) GetSubEvaluator(propertyName string) (Evaluator, error) {
+	subEvaluator, err := e.evaluator.GetSubEvaluator(propertyName)
+	if err != nil || subEvaluator == nil {
+		return subEvaluator, err
+	}
+	return withContext(e.ctx, subEvaluator), nil
+}
+
+func (e *contextBoundEvaluator) GetEvaluatorKind() EvaluatorKind {
+	return e.evaluator.GetEvaluatorKind()
+}
+
+func (e *contextBoundEvaluator) GetArraySubEvaluators() ([]Evaluator, error) {
+	subEvaluators, err := e.evaluator.GetArraySubEvaluators()
+	if err != nil {
+		return nil, err
+	}
+	for i, subEvaluator := range subEvaluators {
+		subEvaluators[i] = withContext(e.ctx, subEvaluator)
+	}
+	return subEvaluators, nil
+}
+
+// Keys enumerates properties of the evaluator if it implements KeysEvaluator.
+// Otherwise it returns no keys, so field-less terms don't match, as for other evaluators without keys.
+func (e *contextBoundEvaluator) Keys() ([]string, error) {
+	if keysEvaluator, ok := e.evaluator.(KeysEvaluator); ok {
+		return keysEvaluator.Keys()
+	}
+	return nil, nil
+}
+
 type NullEvaluator struct {
 }
 
@@ -38,6 +108,10 @@ func (NullEvaluator) GetArraySubEvaluators() ([]Evaluator, error) {
 	return nil, nil
 }
 
+func (NullEvaluator) Keys() ([]string, error) {
+	return nil, nil
+}
+
 type MapEvaluator struct {
 	obj  map[string]any
 	arr  []map[string]any
@@ -95,6 +169,15 @@ func (m *MapEvaluator) GetEvaluatorKind() EvaluatorKind {
 	return m.kind
 }
 
+func (m *MapEvaluator) Keys() ([]string, error) {
+	keys := make([]string, 0, len(m.obj))
+	for key := range m.obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
 func (m *MapEvaluator) GetArraySubEvaluators() ([]Evaluator, error) {
 	if m.GetEvaluatorKind() != EvaluatorKindSlice {
 		return nil, fmt.Errorf("unsupported operation for kind %v", m.kind)
@@ -111,47 +194,110 @@ func (m *MapEvaluator) GetArraySubEvaluators() ([]Evaluator, error) {
 	return res, nil
 }
 
+// ReflectEvaluator evaluates properties of structs and maps using reflection.
+//
+// Properties are resolved by the names in the `kql` struct tag, or in the `json` tag if a field has
+// no `kql` tag, or by the Go names of fields without tags. The Go names of tagged fields are
+// accepted too, unless another field has such a tag name. Fields tagged with "-" are ignored,
+// fields of embedded structs are promoted following the encoding/json rules, and names which
+// don't match exactly are looked up case-insensitively. Resolved fields are cached per type.
+//
+// Pointers and interfaces are dereferenced, nil ones are treated as missing properties. Items of maps
+// are looked up by their string or integer keys. Referring to an unexported field returns
+// an *UnexportedFieldError, as its value cannot be read.
 type ReflectEvaluator struct {
 	value *reflect.Value
+	tags  string
 }
 
+// NewReflectEvaluator creates an evaluator of a struct, a map with string or integer keys, a slice
+// of them or a pointer to any of these.
 func NewReflectEvaluator(value interface{}) *ReflectEvaluator {
 	val := reflect.ValueOf(value)
-	return &ReflectEvaluator{&val}
+	return &ReflectEvaluator{&val, defaultTags}
 }
 
-func (eval *ReflectEvaluator) Evaluate(propertyName string) (interface{}, error) {
+// NewReflectEvaluatorWithTags creates an evaluator resolving property names by the given struct tags
+// in order of precedence instead of the `kql` and `json` tags.
+func NewReflectEvaluatorWithTags(value interface{}, tags ...string) *ReflectEvaluator {
+	val := reflect.ValueOf(value)
+	return &ReflectEvaluator{&val, strings.Join(tags, ",")}
+}
+
+func (eval *ReflectEvaluator) sub(value reflect.Value) *ReflectEvaluator {
+	return &ReflectEvaluator{&value, eval.tags}
+}
+
+// indirect returns the value of the evaluator with pointers and interfaces dereferenced.
+func (eval *ReflectEvaluator) indirect() reflect.Value {
 	if eval.value == nil {
 		panic("evaluator is not initialized")
 	}
+	return indirectValue(*eval.value)
+}
 
-	res := eval.value.FieldByName(propertyName)
-	if res == (reflect.Value{}) {
-		return nil, nil
+func (eval *ReflectEvaluator) Evaluate(propertyName string) (interface{}, error) {
+	property, err := eval.property(propertyName)
+	if err != nil || !property.IsValid() {
+		return nil, err
 	}
 
-	return res.Interface(), nil
+	return property.Interface(), nil
 }
 
-func (eval *ReflectEvaluator) GetSubEvaluator(propertyName string) (Evaluator, error) {
-	if eval.value == nil {
-		panic("evaluator is not initialized")
+// property returns the value of a struct field or of a map item with pointers and interfaces
+// dereferenced. It returns an invalid value if the property is missing or nil.
+func (eval *ReflectEvaluator) property(name string) (reflect.Value, error) {
+	value := eval.indirect()
+
+	var property reflect.Value
+	switch value.Kind() {
+	case reflect.Struct:
+		field, ok := cachedStructFields(value.Type(), eval.tags).lookup(name)
+		if !ok {
+			if field, ok := value.Type().FieldByName(name); ok && !field.IsExported() {
+				return reflect.Value{}, &UnexportedFieldError{Type: value.Type(), Field: name}
+			}
+			return reflect.Value{}, nil
+		}
+
+		var err error
+		if property, err = value.FieldByIndexErr(field.index); err != nil {
+			// the field is promoted through a nil embedded pointer
+			return reflect.Value{}, nil
+		}
+		if !property.CanInterface() {
+			return reflect.Value{}, &UnexportedFieldError{Type: value.Type(), Field: name}
+		}
+	case reflect.Map:
+		key, err := mapKey(value.Type().Key(), name)
+		if err != nil || !key.IsValid() {
+			return reflect.Value{}, err
+		}
+		property = value.MapIndex(key)
+	default:
+		return reflect.Value{}, nil
 	}
 
-	prop, err := eval.Evaluate(propertyName)
+	return indirectValue(property), nil
+}
+
+func (eval *ReflectEvaluator) GetSubEvaluator(propertyName string) (Evaluator, error) {
+	property, err := eval.property(propertyName)
 	if err != nil {
 		return nil, err
 	}
 
-	if prop == nil {
+	if !property.IsValid() {
 		return NullEvaluator{}, nil
 	}
 
-	return NewReflectEvaluator(prop), nil
+	return eval.sub(property), nil
 }
 
 func (eval *ReflectEvaluator) GetEvaluatorKind() EvaluatorKind {
-	if eval.value.Kind() == reflect.Slice {
+	switch eval.indirect().Kind() {
+	case reflect.Slice, reflect.Array:
 		return EvaluatorKindSlice
 	}
 	return EvaluatorKindObject
@@ -161,9 +307,98 @@ func (eval *ReflectEvaluator) GetArraySubEvaluators() ([]Evaluator, error) {
 	if eval.GetEvaluatorKind() != EvaluatorKindSlice {
 		return nil, fmt.Errorf("unsupported operation for kind %v", eval.GetEvaluatorKind())
 	}
-	res := make([]Evaluator, eval.value.Len())
+
+	value := eval.indirect()
+	res := make([]Evaluator, value.Len())
 	for i := range res {
-		res[i] = NewReflectEvaluator(eval.value.Index(i).Interface())
+		res[i] = eval.sub(value.Index(i))
 	}
 	return res, nil
 }
+
+func (eval *ReflectEvaluator) Keys() ([]string, error) {
+	value := eval.indirect()
+	switch value.Kind() {
+	case reflect.Struct:
+		return cachedStructFields(value.Type(), eval.tags).names(), nil
+	case reflect.Map:
+		keys := make([]string, 0, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			if key, ok := mapKeyName(iter.Key()); ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		return keys, nil
+	}
+	return nil, nil
+}
+
+// UnexportedFieldError is returned when a query refers to an unexported struct field,
+// which cannot be read using reflection.
+type UnexportedFieldError struct {
+	Type  reflect.Type
+	Field string
+}
+
+func (e *UnexportedFieldError) Error() string {
+	return fmt.Sprintf("property %s of type %s is unexported", e.Field, e.Type)
+}
+
+// indirectValue dereferences pointers and interfaces. Nil pointers and interfaces are returned as an invalid value.
+func indirectValue(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+// mapKey converts a property name to a key of a map with string, integer or interface keys.
+// It returns an invalid value if the name cannot be a key of the map.
+func mapKey(keyType reflect.Type, name string) (reflect.Value, error) {
+	switch keyType.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(name).Convert(keyType), nil
+	case reflect.Interface:
+		if reflect.TypeOf(name).Implements(keyType) {
+			return reflect.ValueOf(name), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(name, 10, 64)
+		if err != nil || reflect.Zero(keyType).OverflowInt(i) {
+			return reflect.Value{}, nil
+		}
+		return reflect.ValueOf(i).Convert(keyType), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(name, 10, 64)
+		if err != nil || reflect.Zero(keyType).OverflowUint(u) {
+			return reflect.Value{}, nil
+		}
+		return reflect.ValueOf(u).Convert(keyType), nil
+	}
+	return reflect.Value{}, fmt.Errorf("unsupported map key type %s", keyType)
+}
+
+// mapKeyName returns the property name of a map key, the reverse of mapKey.
+func mapKeyName(key reflect.Value) (string, bool) {
+	switch key.Kind() {
+	case reflect.String:
+		return key.String(), true
+	case reflect.Interface:
+		if key.IsNil() {
+			return "", false
+		}
+		if name, ok := key.Elem().Interface().(string); ok {
+			return name, true
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), true
+	}
+	return "", false
+}
@@ -0,0 +1,118 @@
+package gokql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type shapeKey string
+
+type shapeInner struct {
+	Name string
+}
+
+type shapeItem struct {
+	Count    *int
+	Missing  *int
+	Label    *string
+	Inner    *shapeInner
+	NilInner *shapeInner
+	Any      interface{}
+	NilAny   interface{}
+	Labels   map[string]string
+	Items    []*shapeInner
+	private  string
+}
+
+func TestReflectEvaluatorShapes(t *testing.T) {
+	count := 3
+	label := "blue"
+	item := shapeItem{
+		Count:   &count,
+		Label:   &label,
+		Inner:   &shapeInner{Name: "inner"},
+		Any:     &shapeInner{Name: "any"},
+		Labels:  map[string]string{"app": "web"},
+		Items:   []*shapeInner{{Name: "first"}, nil, {Name: "third"}},
+		private: "private",
+	}
+	itemPtr := &item
+	var nilItem *shapeItem
+	var anyItem interface{} = item
+
+	tests := []struct {
+		name     string
+		item     interface{}
+		query    string
+		expected bool
+	}{
+		{"struct", item, "Count:3 and Label:blue", true},
+		{"pointer to struct", &item, "Count:3 and Label:blue", true},
+		{"pointer to pointer", &itemPtr, "Count:3", true},
+		{"interface", &anyItem, "Count:3", true},
+		{"nil pointer", nilItem, "Count:3", false},
+		{"nil pointer negation", nilItem, "not Count:3", true},
+		{"nil value", nil, "Count:*", false},
+		{"nil pointer field", item, "Missing:*", false},
+		{"nil pointer field negation", item, "not Missing:1", true},
+		{"pointer to struct field", item, "Inner.Name:inner and Inner:{Name:inner}", true},
+		{"nil pointer to struct field", item, "NilInner.Name:*", false},
+		{"nil nested pointer", item, "NilInner:{Name:*}", false},
+		{"interface field", item, "Any.Name:any and Any:{Name:any}", true},
+		{"nil interface field", item, "NilAny:* or NilAny.Name:*", false},
+		{"map field", item, "Labels.app:web and Labels:{app:web}", true},
+		{"missing map item", item, "Labels.env:*", false},
+		{"slice of pointers", item, "Items:{Name:third}", true},
+		{"slice of pointers free text", item, "third", true},
+		{"free text", item, "inner and any and web and blue", true},
+		{"map of strings", map[string]string{"a": "1"}, "a:1", true},
+		{"map of named keys", map[shapeKey]int{"a": 1}, "a:1 and not b:*", true},
+		{"map of int keys", map[int]string{42: "x"}, "42:x and not 43:*", true},
+		{"map of int keys with a name", map[int]string{42: "x"}, "a:*", false},
+		{"map of uint8 keys overflow", map[uint8]string{1: "x"}, "1:x and not 256:*", true},
+		{"map of interface keys", map[interface{}]interface{}{"a": 1, 2: "b"}, "a:1", true},
+		{"map of structs", map[string]shapeInner{"x": {Name: "y"}}, "x.Name:y and x:{Name:y}", true},
+		{"pointer to map", &map[string]int{"a": 1}, "a:1", true},
+		{"slice of maps", []map[string]int{{"a": 1}, {"a": 2}}, "a:2", false},
+		{"nested slice of maps", map[string]interface{}{"s": []map[string]int{{"a": 1}, {"a": 2}}}, "s:{a:2}", true},
+		{"free text over map", map[int]interface{}{1: map[string]string{"a": "deep"}}, "deep", true},
+		{"scalar", 42, "a:*", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := mustParse(t, test.query).Match(NewReflectEvaluator(test.item))
+			if err != nil || res != test.expected {
+				t.Errorf("Unexpected result %v, %v for %s. Expected: %v", res, err, test.query, test.expected)
+			}
+		})
+	}
+
+	keys, _ := NewReflectEvaluator(map[interface{}]int{"b": 1, 2: 2, "a": 3}).Keys()
+	if !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("Unexpected keys %q", keys)
+	}
+	keys, _ = NewReflectEvaluator(map[int8]int{-1: 1, 2: 2}).Keys()
+	if !reflect.DeepEqual(keys, []string{"-1", "2"}) {
+		t.Errorf("Unexpected keys %q", keys)
+	}
+}
+
+func TestReflectEvaluatorErrors(t *testing.T) {
+	item := shapeItem{private: "private"}
+	for _, query := range []string{"private:private", "Inner:{x:1} or private:*", "not private:x"} {
+		_, err := mustParse(t, query).Match(NewReflectEvaluator(&item))
+		var unexported *UnexportedFieldError
+		if !errors.As(err, &unexported) || unexported.Field != "private" || unexported.Type != reflect.TypeOf(item) {
+			t.Errorf("Expected unexported field error for %s, got %v", query, err)
+		}
+	}
+
+	if _, err := CompileFor[shapeItem](mustParse(t, "private:x")); !errors.As(err, new(*UnexportedFieldError)) {
+		t.Errorf("Expected unexported field error from compilation, got %v", err)
+	}
+
+	if _, err := mustParse(t, "a:1").Match(NewReflectEvaluator(map[float64]int{1: 1})); err == nil {
+		t.Error("Expected error for unsupported map keys")
+	}
+}
@@ -0,0 +1,272 @@
+package gokql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Explanation describes how a node of an expression was matched against an item.
+// Explanations form a tree mirroring Expression.Root. Operands which Match skips because
+// the result of the enclosing `and` or `or` is already known are included as well, marked
+// as skipped and without explanations of their children.
+type Explanation struct {
+	// Node is the node of the expression the explanation is for.
+	Node Node
+	// Result is the result of matching the node. It is false if the node was skipped or failed.
+	Result bool
+	// Skipped reports whether the node was not evaluated, as the result of the enclosing
+	// `and` or `or` was already known or an earlier node failed.
+	Skipped bool
+	// Field is the dotted name of the property the node was matched against. Elements
+	// of slices are named with their index, for example `tags[1]`.
+	Field string
+	// Missing reports whether the item has no property Field.
+	Missing bool
+	// Property is the value of the property the node was matched against.
+	Property interface{}
+	// Value is the query value converted to the type of the property. Rounded dates,
+	// networks and text phrases are converted to strings describing them.
+	Value interface{}
+	// Comparer names the kind of comparison of Property with Value, for example "string" or "time".
+	Comparer string
+	// Err is the error which failed matching of the node. Match returns the error of the root node.
+	Err error
+	// Children are the explanations of the child nodes, of the elements of a slice property,
+	// of the objects of a slice matched by a nested query or of the default fields
+	// a field-less term was matched against.
+	Children []*Explanation
+}
+
+// Explain matches the item like Match and returns an explanation of the result of every node.
+// The result and the error of the returned root explanation are the ones Match returns.
+func (expression Expression) Explain(evaluator Evaluator) *Explanation {
+	root := &Explanation{}
+	state := expression.newMatchState()
+	state.tracer = &tracer{stack: []tracedNode{{explanation: root}}}
+	expression.ast.match(evaluator, state)
+	return root.Children[0]
+}
+
+// String renders the explanation as an indented tree with one node per line.
+func (e *Explanation) String() string {
+	var builder strings.Builder
+	e.render(&builder, 0)
+	return builder.String()
+}
+
+func (e *Explanation) render(builder *strings.Builder, depth int) {
+	status := strconv.FormatBool(e.Result)
+	switch {
+	case e.Skipped:
+		status = "skipped"
+	case e.Err != nil:
+		status = "error"
+	}
+
+	builder.WriteString(strings.Repeat("  ", depth))
+	builder.WriteString(fmt.Sprintf("%-7s %s", status, explanationLabel(e.Node)))
+	if details := e.details(); details != "" {
+		builder.WriteString("  (" + details + ")")
+	}
+	builder.WriteByte('\n')
+
+	for _, child := range e.Children {
+		child.render(builder, depth+1)
+	}
+}
+
+func (e *Explanation) details() string {
+	var details []string
+	switch _, nested := e.Node.(*NestedNode); {
+	case e.Missing:
+		details = append(details, e.Field+" is missing")
+	case e.Field != "" && e.Property != nil:
+		details = append(details, e.Field+" = "+formatExplainedValue(e.Property))
+	case e.Field != "" && !nested:
+		details = append(details, "in "+e.Field)
+	}
+
+	if e.Comparer != "" {
+		details = append(details, "compared as "+e.Comparer+" with "+formatExplainedValue(e.Value))
+	}
+	if e.Err != nil && !e.childFailed() {
+		details = append(details, "error: "+e.Err.Error())
+	}
+	return strings.Join(details, ", ")
+}
+
+// childFailed reports whether the error of the explanation comes from one of its children.
+func (e *Explanation) childFailed() bool {
+	for _, child := range e.Children {
+		if child.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func explanationLabel(node Node) string {
+	switch node.Kind() {
+	case KindOr, KindValueOr:
+		return "or"
+	case KindAnd, KindValueAnd:
+		return "and"
+	case KindNot, KindValueNot:
+		return "not"
+	}
+	return node.String()
+}
+
+func formatExplainedValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("%v (%T)", value, value)
+}
+
+// tracer records the explanation of a match. Match functions report the nodes they match
+// to the tracer of the match state, so explanations are derived from the matching itself.
+type tracer struct {
+	// stack holds the nodes being matched, the innermost last.
+	stack []tracedNode
+}
+
+type tracedNode struct {
+	explanation *Explanation
+	// operands are the operands of an `and` or `or` node, which are explained as its children.
+	operands []Node
+}
+
+// begin starts the explanation of a node matched as a part of the current node and makes it current.
+func (t *tracer) begin(explanation *Explanation) {
+	traced := tracedNode{explanation: explanation}
+	switch node := explanation.Node.(type) {
+	case *AndNode:
+		traced.operands = node.Children
+	case *OrNode:
+		traced.operands = node.Children
+	case *ValueAndNode:
+		traced.operands = node.Values
+	case *ValueOrNode:
+		traced.operands = node.Values
+	}
+	t.push(traced)
+}
+
+// beginElement starts the explanation of the match of an element of a slice. The element
+// is named after the innermost property being matched.
+func (t *tracer) beginElement(node Node, index int) {
+	field := ""
+	for i := len(t.stack) - 1; i >= 0 && field == ""; i-- {
+		field = t.stack[i].explanation.Field
+	}
+	t.push(tracedNode{explanation: &Explanation{Node: node, Field: field + "[" + strconv.Itoa(index) + "]"}})
+}
+
+func (t *tracer) push(traced tracedNode) {
+	parent := t.current()
+	parent.Children = append(parent.Children, traced.explanation)
+	t.stack = append(t.stack, traced)
+}
+
+func (t *tracer) current() *Explanation {
+	return t.stack[len(t.stack)-1].explanation
+}
+
+// end completes the current explanation with the result of matching its node and returns the result.
+// Operands of an `and` or `or` which were not matched are added as skipped.
+func (t *tracer) end(result bool, err error) (bool, error) {
+	if t == nil {
+		return result, err
+	}
+
+	traced := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+	explanation := traced.explanation
+	explanation.Result, explanation.Err = result && err == nil, err
+	for i := len(explanation.Children); i < len(traced.operands); i++ {
+		explanation.Children = append(explanation.Children, &Explanation{Node: traced.operands[i], Skipped: true})
+	}
+	return result, err
+}
+
+// property records the value of the property the current node is matched against.
+func (t *tracer) property(property interface{}) {
+	if t != nil {
+		t.current().Property = property
+	}
+}
+
+// missing records that the property the current node is matched against is missing.
+func (t *tracer) missing() {
+	if t != nil {
+		t.current().Missing = true
+	}
+}
+
+// comparison records the comparer the current value is compared with the converted property by.
+func (t *tracer) comparison(property interface{}, converted interface{}, atomic *atomicValue, comparer comparer) {
+	explanation := t.current()
+	explanation.Property = property
+	if atomic.wildcard.matchesAll() {
+		return
+	}
+	if typed, err := atomic.typedComparer(converted, comparer); err == nil {
+		explanation.Comparer, explanation.Value = explainHandler(typed.handler, typed.requestValue)
+	}
+}
+
+// explainHandler names a type handler and converts its query value to a printable form.
+func explainHandler(handler typeHandler, value interface{}) (string, interface{}) {
+	switch h := handler.(type) {
+	case customTypeHandler:
+		return fmt.Sprintf("%T", h.handler), value
+	case stringTypeHandler:
+		return "string", value
+	case regexpTypeHandler:
+		return "regexp", formatRegexp(h.pattern)
+	case textTypeHandler:
+		if h.wildcard.isPattern() {
+			return "text tokens", h.wildcard.format("*", escapePattern)
+		}
+		if h.phrase {
+			return "text phrase", value.(textQuery).tokens
+		}
+		return "text tokens", value.(textQuery).tokens
+	case timeTypeHandler:
+		start, end := h.interval(value)
+		if start.Equal(end) {
+			return "time", start
+		}
+		return "time", start.Format(time.RFC3339Nano) + " - " + end.Format(time.RFC3339Nano)
+	case ipTypeHandler:
+		query := value.(ipQuery)
+		if query.pattern {
+			return "ip", h.wildcard.format("*", escapePattern)
+		}
+		if query.first == query.last {
+			return "ip", query.first
+		}
+		return "ip", query.first.String() + " - " + query.last.String()
+	case int64TypeHandler:
+		return "int64", value
+	case uint64TypeHandler:
+		return "uint64", value
+	case float64TypeHandler:
+		return "float64", value
+	case numberPatternTypeHandler:
+		return "number pattern", value
+	case boolTypeHandler:
+		return "bool", value
+	case durationTypeHandler:
+		return "duration", value
+	}
+	return fmt.Sprintf("%T", handler), value
+}
@@ -0,0 +1,131 @@
+package gokql
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplainMatchesMatch(t *testing.T) {
+	obj := map[string]interface{}{
+		"status":  200,
+		"level":   "warn",
+		"tags":    []string{"a", "b"},
+		"ts":      time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+		"items":   []interface{}{map[string]interface{}{"n": 1}, map[string]interface{}{"n": 2}},
+		"message": "connection refused",
+		"ip":      "10.1.2.3",
+	}
+	evaluator, _ := NewMapEvaluator(obj)
+	options := ParseOptions{
+		DefaultFields: []string{"level", "message"},
+		Schema:        Schema{"ip": {Type: FieldIP}},
+	}
+
+	queries := []string{
+		"status:200",
+		"status:200 and level:error",
+		"status:201 or level:warn or missing:1",
+		"not status<100 and tags:(b and not c)",
+		"tags>a",
+		"items:{n>1} and items:{n>5}",
+		"ts:2024-01-01 and ts<now",
+		"warn and not refused",
+		"ip:10.0.0.0/8",
+		"status:abc",
+		"level:warn or status:abc",
+		"level:error or status:abc",
+		"level:*",
+		"not missing:* and items:* and tags:(* or x)",
+		"nested:{a:1}",
+		"items:{n:1 or n:3}",
+		"*.n:2 and i*:{n>1}",
+		"t*:(c or b) and not *s:abc",
+	}
+	for _, query := range queries {
+		expr, err := ParseWithOptions(query, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedResult, expectedErr := expr.Match(evaluator)
+		explanation := expr.Explain(evaluator)
+		if explanation.Result != expectedResult || (explanation.Err == nil) != (expectedErr == nil) {
+			t.Errorf("Explanation result %v, %v differs from match result %v, %v for %s",
+				explanation.Result, explanation.Err, expectedResult, expectedErr, query)
+		}
+		if explanation.Node.String() != expr.Root().String() {
+			t.Errorf("Explained node %s differs from %s", explanation.Node, expr.Root())
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	obj := map[string]interface{}{
+		"status": 200,
+		"level":  "warn",
+		"tags":   []string{"a", "b"},
+	}
+	evaluator, _ := NewMapEvaluator(obj)
+
+	explanation := mustParse(t, "status:200 and (level:error or tags:b) and missing:1 and other:2").Explain(evaluator)
+	if explanation.Result || explanation.Node.Kind() != KindAnd || len(explanation.Children) != 4 {
+		t.Fatalf("Unexpected explanation:\n%s", explanation)
+	}
+
+	status := explanation.Children[0]
+	if !status.Result || status.Field != "status" || status.Property != 200 || len(status.Children) != 1 {
+		t.Errorf("Unexpected explanation of status:\n%s", status)
+	}
+	if value := status.Children[0]; value.Comparer != "int64" || value.Value != int64(200) || !value.Result {
+		t.Errorf("Unexpected explanation of the status value:\n%s", value)
+	}
+
+	or := explanation.Children[1]
+	if !or.Result || or.Node.Kind() != KindOr || or.Children[0].Result || !or.Children[1].Result {
+		t.Errorf("Unexpected explanation of or:\n%s", or)
+	}
+	if elements := or.Children[1].Children[0].Children; len(elements) != 2 || elements[0].Field != "tags[0]" || !elements[1].Result {
+		t.Errorf("Unexpected explanation of slice elements:\n%s", or.Children[1])
+	}
+
+	if missing := explanation.Children[2]; missing.Result || !missing.Missing || missing.Skipped {
+		t.Errorf("Unexpected explanation of a missing property:\n%s", missing)
+	}
+	if skipped := explanation.Children[3]; !skipped.Skipped || skipped.Node.String() != "other:2" {
+		t.Errorf("Unexpected explanation of a skipped node:\n%s", skipped)
+	}
+
+	expected := strings.Join([]string{
+		"false   and",
+		"  true    status:200  (status = 200 (int))",
+		"    true    200  (compared as int64 with 200 (int64))",
+		"  true    or",
+		`    false   level:error  (level = "warn")`,
+		`      false   error  (compared as string with "error")`,
+		"    true    tags:b  (tags = [a b] ([]string))",
+		"      true    b",
+		`        false   b  (tags[0] = "a", compared as string with "b")`,
+		`        true    b  (tags[1] = "b", compared as string with "b")`,
+		"  false   missing:1  (missing is missing)",
+		"  skipped other:2",
+		"",
+	}, "\n")
+	if actual := explanation.String(); actual != expected {
+		t.Errorf("Unexpected rendering:\n%s\nExpected:\n%s", actual, expected)
+	}
+
+	explanation = mustParse(t, "level:warn and not status:abc").Explain(evaluator)
+	var numError *strconv.NumError
+	if explanation.Err == nil || !errors.As(explanation.Err, &numError) || !strings.Contains(explanation.String(), "error   abc  (error: ") {
+		t.Errorf("Unexpected explanation of an error:\n%s", explanation)
+	}
+
+	nested, _ := NewMapEvaluator(map[string]interface{}{"items": []interface{}{map[string]interface{}{"n": 1}}})
+	explanation = mustParse(t, "items:{n:1 or n:3}").Explain(nested)
+	if element := explanation.Children[0]; element.Field != "items[0]" || len(element.Children) != 1 || !element.Children[0].Children[1].Skipped {
+		t.Errorf("Unexpected explanation of a slice element:\n%s", explanation)
+	}
+}
@@ -0,0 +1,150 @@
+package gokql
+
+import (
+	"strings"
+)
+
+// fieldPattern matches dotted property paths against a field name containing wildcards,
+// such as `machine.os*` or `*.status`. Like in Kibana, a star matches any characters including dots,
+// so `*.status` matches both `http.status` and `http.response.status`.
+type fieldPattern struct {
+	// prefix holds the names before the first name with a wildcard, which are looked up directly.
+	prefix []string
+	// wildcard matches the rest of the path joined with dots.
+	wildcard wildcard
+}
+
+// newFieldPattern creates a pattern from the names of a field in pattern form.
+// It returns nil if none of the names contains a wildcard.
+func newFieldPattern(names []string) *fieldPattern {
+	for i, name := range names {
+		if !newWildcard(name).isPattern() {
+			continue
+		}
+
+		prefix := make([]string, i)
+		for j := range prefix {
+			prefix[j] = unescapePattern(names[j])
+		}
+		return &fieldPattern{
+			prefix:   prefix,
+			wildcard: newWildcard(strings.Join(names[i:], ".")),
+		}
+	}
+	return nil
+}
+
+// forEachField calls match for every property whose path matches the pattern, passing the evaluator
+// of the object holding the property, the name of the property and its path relative to the evaluator.
+// Properties are enumerated with KeysEvaluator, descending into nested objects and slices of objects.
+// It stops when match returns true.
+func (p *fieldPattern) forEachField(evaluator Evaluator, match func(ev Evaluator, name string, path string) (bool, error)) (bool, error) {
+	evaluator, err := drilldownEvaluator(p.prefix, evaluator)
+	if err != nil || evaluator == nil {
+		return false, err
+	}
+
+	prefix := ""
+	if len(p.prefix) > 0 {
+		prefix = strings.Join(p.prefix, ".") + "."
+	}
+	return p.walk(evaluator, prefix, "", match, 0)
+}
+
+func (p *fieldPattern) walk(evaluator Evaluator, prefix string, path string, match func(Evaluator, string, string) (bool, error), depth int) (bool, error) {
+	if depth > maxFreeTextDepth {
+		return false, nil
+	}
+
+	if evaluator.GetEvaluatorKind() == EvaluatorKindSlice {
+		elements, err := evaluator.GetArraySubEvaluators()
+		if err != nil {
+			return false, err
+		}
+		for _, element := range elements {
+			res, err := p.walk(element, prefix, path, match, depth+1)
+			if err != nil || res {
+				return res, err
+			}
+		}
+		return false, nil
+	}
+
+	keysEvaluator, ok := evaluator.(KeysEvaluator)
+	if !ok {
+		return false, nil
+	}
+	keys, err := keysEvaluator.Keys()
+	if err != nil {
+		return false, err
+	}
+
+	for _, key := range keys {
+		keyPath := path + key
+		if p.wildcard.Match(keyPath) {
+			res, err := match(evaluator, key, prefix+keyPath)
+			if err != nil || res {
+				return res, err
+			}
+		}
+
+		property, err := evaluator.Evaluate(key)
+		if err != nil {
+			return false, err
+		}
+		if property == nil || !isNestedValue(property) {
+			continue
+		}
+
+		// Values the evaluator cannot walk into, such as maps of strings in a MapEvaluator, are skipped.
+		subEvaluator, err := evaluator.GetSubEvaluator(key)
+		if err != nil || subEvaluator == nil {
+			continue
+		}
+		res, err := p.walk(subEvaluator, prefix, keyPath+".", match, depth+1)
+		if err != nil || res {
+			return res, err
+		}
+	}
+
+	return false, nil
+}
+
+// matchFieldPattern matches every property whose path matches the field pattern and reports whether any of them matched.
+// A value which cannot be converted to the type of a property doesn't match it, as the properties can be of different types.
+func (prop propertyMatch) matchFieldPattern(evaluator Evaluator, state *matchState) (bool, error) {
+	return prop.pattern.forEachField(evaluator, func(ev Evaluator, name string, path string) (bool, error) {
+		property, err := ev.Evaluate(name)
+		if err != nil {
+			return false, err
+		}
+
+		if prop.ValueSubExpression != nil {
+			if property == nil || !isNestedValue(property) {
+				return false, nil
+			}
+			if state.tracer != nil {
+				state.tracer.begin(&Explanation{Node: prop.fieldNode(path), Field: path})
+			}
+			subEvaluator, err := ev.GetSubEvaluator(name)
+			if err != nil || subEvaluator == nil {
+				return state.tracer.end(false, nil)
+			}
+			return state.tracer.end(matchSubEvaluator(subEvaluator, prop.ValueSubExpression, state.nested(path)))
+		}
+
+		if state.tracer != nil {
+			state.tracer.begin(&Explanation{Node: prop.fieldNode(path), Field: path, Property: property})
+		}
+		res, err := state.tracer.end(prop.matchProperty(property, state.withField(path)))
+		return err == nil && res, nil
+	})
+}
+
+// fieldNode returns the node of the pattern match applied to the property with the dotted path.
+func (prop propertyMatch) fieldNode(path string) Node {
+	field := prop
+	field.Name = strings.Split(path, ".")
+	field.pattern = nil
+	return field.node()
+}
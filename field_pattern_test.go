@@ -0,0 +1,177 @@
+package gokql
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFieldPatterns(t *testing.T) {
+	obj := map[string]interface{}{
+		"machine": map[string]interface{}{
+			"os":         "windows",
+			"os_version": "10",
+			"ram":        16,
+		},
+		"http": map[string]interface{}{
+			"status":   "ok",
+			"response": map[string]interface{}{"status": 503},
+		},
+		"events": []interface{}{
+			map[string]interface{}{"code": 1, "kind": "start"},
+			map[string]interface{}{"code": 2, "kind": "stop"},
+		},
+		"a*b": "literal star",
+		"tags": map[string]interface{}{
+			"env":  "prod",
+			"team": "core",
+		},
+		"labels": map[string]string{"app": "web"},
+	}
+
+	queries := map[string]bool{
+		"machine.os*:windows":               true,
+		"machine.os*:10":                    true,
+		"machine.os*:linux":                 false,
+		"machine.*:16":                      true,
+		"machine.*:*":                       true,
+		"*.status:503":                      true,
+		"*.status:ok":                       true,
+		"*.status>500":                      true,
+		"*.status<500":                      false,
+		"*status:503":                       true,
+		"http.*.status:503":                 true,
+		"http.*.status:ok":                  false,
+		"events.c*:2":                       true,
+		"events.*:stop":                     true,
+		"events.*:pause":                    false,
+		"*:windows":                         true,
+		"*:(core and not dev)":              true,
+		"tags.*:(prod and core)":            false,
+		"not m*.os:windows":                 false,
+		"unknown*:*":                        false,
+		"not unknown.*:*":                   true,
+		`a\*b:"literal star"`:               true,
+		`a*b:"literal star"`:                true,
+		`a\*c:*`:                            false,
+		"http.*:{status:503}":               true,
+		"*:{code:2 and kind:stop}":          true,
+		"*:{code:2 and kind:start}":         false,
+		"mach*:{os:windows and ram>8}":      true,
+		"machine.os*:windows and tags.e*:*": true,
+	}
+
+	data, _ := json.Marshal(obj)
+	jsonEvaluator, err := NewJSONEvaluator(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluators := map[string]Evaluator{
+		"map":     mustMapEvaluator(t, obj),
+		"reflect": NewReflectEvaluator(obj),
+		"json":    jsonEvaluator,
+	}
+	for query, expected := range queries {
+		expr := mustParse(t, query)
+		for name, evaluator := range evaluators {
+			res, err := expr.Match(evaluator)
+			if err != nil || res != expected {
+				t.Errorf("Unexpected result %v, %v of %s evaluator for %s. Expected: %v", res, err, name, query, expected)
+			}
+
+			explanation := expr.Explain(evaluator)
+			if explanation.Result != res || explanation.Err != nil {
+				t.Errorf("Explanation result %v, %v differs from match result for %s", explanation.Result, explanation.Err, query)
+			}
+		}
+	}
+
+	// A MapEvaluator cannot walk into maps of strings, so their values don't match instead of failing.
+	for query, expected := range map[string]bool{"*:web": true, "lab*:{app:web}": true} {
+		expr := mustParse(t, query)
+		if res, err := expr.Match(evaluators["reflect"]); err != nil || res != expected {
+			t.Errorf("Unexpected result %v, %v of reflect evaluator for %s. Expected: %v", res, err, query, expected)
+		}
+		if res, err := expr.Match(evaluators["map"]); err != nil || res {
+			t.Errorf("Unexpected result %v, %v of map evaluator for %s. Expected: false", res, err, query)
+		}
+	}
+}
+
+func TestFieldPatternNodes(t *testing.T) {
+	for query, expected := range map[string]string{
+		"a*:1":       "a*:1",
+		`a\*:1`:      `a\*:1`,
+		`a\*b*.c:1`:  `a\*b*.c:1`,
+		`a\\*:1`:     `a\\*:1`,
+		"a.*:{b*:1}": "a.*:{b*:1}",
+	} {
+		expr := mustParse(t, query)
+		if actual := expr.String(); actual != expected {
+			t.Errorf("Unexpected string %s of %s. Expected: %s", actual, query, expected)
+		}
+		if actual := expr.Root().String(); actual != expected {
+			t.Errorf("Unexpected node string %s of %s. Expected: %s", actual, query, expected)
+		}
+	}
+
+	match := mustParse(t, `a\*b*:1`).Root().(*MatchNode)
+	if !match.FieldPattern || len(match.Field) != 1 || match.Field[0] != `a\*b*` {
+		t.Errorf("Unexpected match node %#v", match)
+	}
+	literal := mustParse(t, `a\*b:1`).Root().(*MatchNode)
+	if literal.FieldPattern || literal.Field[0] != "a*b" {
+		t.Errorf("Unexpected match node %#v", literal)
+	}
+
+	rewritten, err := Rewrite(mustParse(t, "x:1"), func(node Node) (Node, error) {
+		if match, ok := node.(*MatchNode); ok {
+			return &MatchNode{Field: []string{"machine", "os*"}, FieldPattern: true, Operator: ":", Value: match.Value}, nil
+		}
+		return node, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluator := mustMapEvaluator(t, map[string]interface{}{"machine": map[string]interface{}{"os_version": 1}})
+	if res, err := rewritten.Match(evaluator); err != nil || !res || rewritten.String() != "machine.os*:1" {
+		t.Errorf("Unexpected match %v, %v of rewritten expression %s", res, err, rewritten)
+	}
+
+	literalStar, _ := Rewrite(mustParse(t, "x:1"), func(node Node) (Node, error) {
+		if match, ok := node.(*MatchNode); ok {
+			return &MatchNode{Field: []string{"machine", "os*"}, Operator: ":", Value: match.Value}, nil
+		}
+		return node, nil
+	})
+	if res, err := literalStar.Match(evaluator); err != nil || res || literalStar.String() != `machine.os\*:1` {
+		t.Errorf("Unexpected match %v, %v of rewritten expression %s", res, err, literalStar)
+	}
+}
+
+func TestFieldPatternTranslations(t *testing.T) {
+	type Machine struct {
+		OS        string `json:"os"`
+		OSVersion string `json:"os_version"`
+	}
+	type Item struct {
+		Machine Machine           `json:"machine"`
+		Labels  map[string]string `json:"labels"`
+	}
+
+	match, err := CompileFor[Item](mustParse(t, "machine.os*:10 and labels.*:web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := Item{Machine: Machine{"linux", "10"}, Labels: map[string]string{"app": "web"}}
+	if res, err := match(&item); err != nil || !res {
+		t.Errorf("Unexpected compiled match %v, %v", res, err)
+	}
+
+	if _, _, err := mustParse(t, "a*:1").SQL(SQLOptions{}); err == nil {
+		t.Error("Expected error for field name pattern in SQL")
+	}
+	if _, err := mustParse(t, "a*:{b:1}").ElasticsearchQuery(); err == nil {
+		t.Error("Expected error for nested query on field name pattern in Elasticsearch")
+	}
+}
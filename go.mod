@@ -3,6 +3,8 @@ module github.com/vladimir-rom/gokql
 go 1.18
 
 require (
-	github.com/alecthomas/participle v0.7.1 // indirect
-	github.com/alecthomas/participle/v2 v2.0.0-alpha5 // indirect
+	github.com/alecthomas/participle v0.7.1
+	golang.org/x/text v0.16.0
 )
+
+require github.com/alecthomas/participle/v2 v2.0.0-alpha5 // indirect
@@ -1,86 +1,260 @@
 package gokql
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/netip"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 )
 
+// maxFreeTextDepth bounds how deep field-less terms descend into nested objects.
+const maxFreeTextDepth = 32
+
+// matchState holds the settings shared by all nodes during a single Match call.
+type matchState struct {
+	defaultFields   [][]string
+	rangeQuantifier ArrayQuantifier
+	missing         MissingPolicy
+	// ctx is the context of MatchContext. It is nil for Match, which cannot be cancelled.
+	ctx context.Context
+	// tracer records the explanation of Explain. It is nil for Match.
+	tracer *tracer
+	// schema is the schema of the expression. Field matches are bound to it when the query is
+	// parsed, properties resolved while matching are looked up in it by their dotted path.
+	schema Schema
+	// prefix is the dotted path of the object matched by a nested sub-expression, followed by a dot.
+	// It is only tracked if the schema is not empty.
+	prefix string
+	// field is the dotted path of a property resolved while matching which is declared in the schema,
+	// such as a property matching a field name pattern. Values compared with it are bound to its schema.
+	field string
+}
+
 func (expression Expression) Match(evaluator Evaluator) (bool, error) {
-	return expression.ast.match(evaluator)
+	return expression.ast.match(evaluator, expression.newMatchState())
 }
 
-func (prop propertyMatch) match(evaluator Evaluator) (bool, error) {
-	if prop.AtomicValue != nil {
-		return matchAtomicValue(evaluator, prop)
-	} else if prop.ValueSubExpression != nil {
-		return matchSubExpression(evaluator, prop)
-	} else if prop.OrValues != nil {
-		return matchOrValues(evaluator, prop)
-	} else if prop.AndValues != nil {
-		return matchAndValues(evaluator, prop)
+// MatchContext matches the evaluator like Match, but stops with the error of the context
+// once it is done. The context is checked between the clauses of `and` and `or` expressions
+// and passed to evaluators implementing ContextEvaluator, so properties fetched from slow
+// sources can be cancelled. Other evaluators are used as they are.
+func (expression Expression) MatchContext(ctx context.Context, evaluator Evaluator) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
 	}
 
-	return false, errors.New("not implemented")
+	state := expression.newMatchState()
+	state.ctx = ctx
+	return expression.ast.match(withContext(ctx, evaluator), state)
 }
 
-func matchAtomicValue(evaluator Evaluator, prop propertyMatch) (bool, error) {
+// done returns the error of the context of the match if the context is done.
+func (state *matchState) done() error {
+	if state.ctx == nil {
+		return nil
+	}
+	return state.ctx.Err()
+}
+
+func (expression Expression) newMatchState() *matchState {
+	return &matchState{
+		defaultFields:   expression.defaultFields,
+		rangeQuantifier: expression.options.RangeQuantifier,
+		missing:         expression.options.Missing,
+		schema:          expression.options.Schema,
+	}
+}
+
+// nested returns the state of matching a nested sub-expression of the property with the dotted path.
+func (state *matchState) nested(path string) *matchState {
+	if len(state.schema) == 0 {
+		return state
+	}
+
+	nested := *state
+	nested.prefix = state.prefix + path + "."
+	nested.field = ""
+	return &nested
+}
+
+// withField returns the state of matching the values of a property resolved while matching,
+// which are bound to the schema of the property if it declares it.
+func (state *matchState) withField(path string) *matchState {
+	if len(state.schema) == 0 {
+		return state
+	}
+
+	field := state.prefix + path
+	if _, ok := state.schema[field]; !ok {
+		return state
+	}
+	bound := *state
+	bound.field = field
+	return &bound
+}
+
+// fieldPath returns the dotted path of a property resolved while matching a field-less term.
+// Paths are only needed to look properties up in the schema, so it is empty if there is no schema.
+func (state *matchState) fieldPath(prefix string, names ...string) string {
+	if len(state.schema) == 0 {
+		return ""
+	}
+	return prefix + strings.Join(names, ".")
+}
+
+// schemaValue returns the value bound to the schema of the property with the dotted path,
+// or the value itself if the schema doesn't declare the property.
+func (state *matchState) schemaValue(atomic *atomicValue, path string) *atomicValue {
+	field, ok := state.schema[path]
+	if !ok {
+		return atomic
+	}
+	return atomic.withSchema(path, field)
+}
+
+func (prop propertyMatch) match(evaluator Evaluator, state *matchState) (bool, error) {
+	if state.tracer != nil {
+		state.tracer.begin(&Explanation{Node: prop.node(), Field: strings.Join(prop.Name, ".")})
+	}
+
+	if prop.pattern != nil {
+		return state.tracer.end(prop.matchFieldPattern(evaluator, state))
+	}
+
+	if prop.ValueSubExpression != nil {
+		return state.tracer.end(matchSubExpression(evaluator, prop, state))
+	}
+
 	property, err := evaluateWithDrilldown(evaluator, prop.Name)
 	if err != nil {
-		return false, err
+		return state.tracer.end(false, err)
 	}
 
-	if property == nil {
+	state.tracer.property(property)
+	if state.prefix != "" {
+		state = state.withField(strings.Join(prop.Name, "."))
+	}
+	return state.tracer.end(prop.matchProperty(property, state))
+}
+
+// matchProperty matches an evaluated property value with the atomic value or the value list of the property match.
+// Missing properties don't match, values of other properties of any type match the existence query `field:*`.
+func (prop propertyMatch) matchProperty(property interface{}, state *matchState) (bool, error) {
+	if state.missing.isMissing(property) {
+		state.tracer.missing()
 		return false, nil
 	}
 
-	propertyValue := reflect.ValueOf(property)
+	if prop.AtomicValue != nil {
+		return matchAtomicValue(property, prop.AtomicValue, prop.Operation, state)
+	} else if prop.ValueList != nil {
+		return prop.ValueList.match(property, prop.Operation, state)
+	}
 
-	if propertyValue.Kind() == reflect.Slice {
-		sliceLen := propertyValue.Len()
-		for i := 0; i < sliceLen; i++ {
-			res, err := compare(propertyValue.Index(i).Interface(), prop.AtomicValue, equalCmp{})
-			if err != nil {
-				return false, err
-			}
-			if res {
-				return true, nil
+	return false, errors.New("not implemented")
+}
+
+// isMissing reports whether the property value is treated as missing.
+func (p MissingPolicy) isMissing(property interface{}) bool {
+	if property == nil {
+		return true
+	}
+	return p.isMissingValue(reflect.ValueOf(property))
+}
+
+func (p MissingPolicy) isMissingValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil() || p.isMissingValue(value.Elem())
+	case reflect.String:
+		return p.EmptyStrings && value.Len() == 0
+	case reflect.Map:
+		return value.IsNil() || (p.EmptyCollections && value.Len() == 0)
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return true
+		}
+		if !p.EmptyCollections {
+			return false
+		}
+		for i := 0; i < value.Len(); i++ {
+			if !p.isMissingValue(value.Index(i)) {
+				return false
 			}
 		}
-		return false, nil
-	} else {
-		switch prop.Operation {
-		case ":":
-			return compare(property, prop.AtomicValue, equalCmp{})
-		case ">":
-			return compare(property, prop.AtomicValue, greaterCmp{})
-		case ">=":
-			return compare(property, prop.AtomicValue, greaterOrEqualCmp{})
-		case "<":
-			return compare(property, prop.AtomicValue, lessCmp{})
-		case "<=":
-			return compare(property, prop.AtomicValue, lessOrEqualCmp{})
-		default:
-			panic("unknown operation " + prop.Operation)
+		return true
+	}
+	return false
+}
+
+// matchAtomicValue compares a property with a single value. A slice property matches if any of its elements
+// matches, or for range operations with the AllElements quantifier, if all of its elements match.
+func matchAtomicValue(property interface{}, atomic *atomicValue, operation string, state *matchState) (bool, error) {
+	if state.tracer != nil {
+		state.tracer.begin(&Explanation{Node: atomic.node()})
+	}
+	if state.field != "" {
+		atomic = state.schemaValue(atomic, state.field)
+	}
+
+	if operation == ":" && atomic.isExistence() {
+		state.tracer.property(property)
+		return state.tracer.end(true, nil)
+	}
+
+	propertyValue := reflect.ValueOf(property)
+	comparer := operationComparer(operation)
+
+	if !atomic.isSliceProperty(property) {
+		return state.tracer.end(state.compare(property, atomic, comparer))
+	}
+
+	// Equality on a slice always means "contains", the quantifier applies to range comparisons only.
+	matchAll := operation != ":" && state.rangeQuantifier == AllElements
+	sliceLen := propertyValue.Len()
+	for i := 0; i < sliceLen; i++ {
+		if state.tracer != nil {
+			state.tracer.beginElement(atomic.node(), i)
+		}
+		res, err := state.tracer.end(state.compare(propertyValue.Index(i).Interface(), atomic, comparer))
+		if err != nil {
+			return state.tracer.end(false, err)
+		}
+		if res && !matchAll {
+			return state.tracer.end(true, nil)
+		}
+		if !res && matchAll {
+			return state.tracer.end(false, nil)
 		}
 	}
 
+	return state.tracer.end(matchAll && sliceLen > 0, nil)
 }
 
-func matchSubExpression(evaluator Evaluator, prop propertyMatch) (bool, error) {
+func matchSubExpression(evaluator Evaluator, prop propertyMatch, state *matchState) (bool, error) {
 	subEvaluator, err := drilldownEvaluator(prop.Name, evaluator)
 	if err != nil {
 		return false, err
 	}
 
 	if subEvaluator == nil {
+		state.tracer.missing()
 		return false, nil
 	}
 
+	return matchSubEvaluator(subEvaluator, prop.ValueSubExpression, state.nested(strings.Join(prop.Name, ".")))
+}
+
+// matchSubEvaluator matches a nested sub-expression against an object or against every object of a slice.
+func matchSubEvaluator(subEvaluator Evaluator, expr *expression, state *matchState) (bool, error) {
 	if subEvaluator.GetEvaluatorKind() == EvaluatorKindObject {
-		return prop.ValueSubExpression.match(subEvaluator)
+		return expr.match(subEvaluator, state)
 	}
 
 	sliceEvals, err := subEvaluator.GetArraySubEvaluators()
@@ -88,8 +262,11 @@ func matchSubExpression(evaluator Evaluator, prop propertyMatch) (bool, error) {
 		return false, err
 	}
 
-	for _, ev := range sliceEvals {
-		res, err := prop.ValueSubExpression.match(ev)
+	for i, ev := range sliceEvals {
+		if state.tracer != nil {
+			state.tracer.beginElement(expr.node(), i)
+		}
+		res, err := state.tracer.end(expr.match(ev, state))
 		if err != nil {
 			return false, err
 		}
@@ -128,115 +305,305 @@ func evaluateWithDrilldown(evaluator Evaluator, propName []string) (any, error)
 	return subEvaluator.Evaluate(propName[len(propName)-1])
 }
 
-func matchOrValues(evaluator Evaluator, prop propertyMatch) (bool, error) {
-	property, err := evaluateWithDrilldown(evaluator, prop.Name)
+func matchFreeText(evaluator Evaluator, atomic *atomicValue, state *matchState) (bool, error) {
+	if state.tracer != nil {
+		state.tracer.begin(&Explanation{Node: &TermNode{Value: atomic.node()}})
+	}
+
+	if len(state.defaultFields) == 0 {
+		return state.tracer.end(matchAnyField(evaluator, atomic, state, state.prefix, 0))
+	}
+
+	for _, field := range state.defaultFields {
+		res, err := matchDefaultField(evaluator, field, atomic, state)
+		if err != nil {
+			return state.tracer.end(false, err)
+		}
+		if res {
+			return state.tracer.end(true, nil)
+		}
+	}
+
+	return state.tracer.end(false, nil)
+}
+
+// matchDefaultField matches a field-less term with one of the default fields.
+func matchDefaultField(evaluator Evaluator, field []string, atomic *atomicValue, state *matchState) (bool, error) {
+	if state.tracer != nil {
+		state.tracer.begin(&Explanation{Node: atomic.node(), Field: strings.Join(field, ".")})
+	}
+
+	subEvaluator, err := drilldownEvaluator(field[:len(field)-1], evaluator)
 	if err != nil {
-		return false, err
+		return state.tracer.end(false, err)
+	}
+
+	if subEvaluator == nil {
+		state.tracer.missing()
+		return state.tracer.end(false, nil)
+	}
+
+	name := field[len(field)-1]
+	property, err := subEvaluator.Evaluate(name)
+	if err != nil {
+		return state.tracer.end(false, err)
 	}
 
 	if property == nil {
+		state.tracer.missing()
+		return state.tracer.end(false, nil)
+	}
+
+	state.tracer.property(property)
+	path := state.fieldPath(state.prefix, field...)
+	return state.tracer.end(matchFreeTextProperty(subEvaluator, name, property, atomic, state, path, 0))
+}
+
+// matchAnyField matches a field-less term with all fields of an object. Prefix is the dotted path of the object followed by a dot.
+func matchAnyField(evaluator Evaluator, atomic *atomicValue, state *matchState, prefix string, depth int) (bool, error) {
+	keysEvaluator, ok := evaluator.(KeysEvaluator)
+	if !ok || depth > maxFreeTextDepth {
 		return false, nil
 	}
 
-	propertyValue := reflect.ValueOf(property)
-	kind := propertyValue.Kind()
-	if kind != reflect.String && kind != reflect.Int && kind != reflect.Slice {
-		return false, errors.New("not implemented")
+	keys, err := keysEvaluator.Keys()
+	if err != nil {
+		return false, err
 	}
 
-	if kind == reflect.Slice {
-		sliceLen := propertyValue.Len()
-		for i := 0; i < sliceLen; i++ {
-			sliceItem := propertyValue.Index(i).Interface()
-
-			for _, item := range prop.OrValues {
-				itemValue, err := compare(sliceItem, &item, equalCmp{})
-				if err != nil {
-					return false, err
-				}
-				if itemValue {
-					return true, nil
-				}
-			}
+	for _, key := range keys {
+		res, err := matchFreeTextField(evaluator, key, atomic, state, state.fieldPath(prefix, key), depth)
+		if err != nil {
+			return false, err
 		}
-	} else {
-		for _, item := range prop.OrValues {
-			itemValue, err := compare(property, &item, equalCmp{})
-			if err != nil {
-				return false, err
-			}
-			if itemValue {
-				return true, nil
-			}
+		if res {
+			return true, nil
 		}
 	}
 
 	return false, nil
 }
 
-func matchAndValues(evaluator Evaluator, prop propertyMatch) (bool, error) {
-	property, err := evaluateWithDrilldown(evaluator, prop.Name)
+// matchFreeTextField matches a field-less term with a property of an object.
+// Path is the dotted path of the property, which is empty if the expression has no schema.
+func matchFreeTextField(evaluator Evaluator, name string, atomic *atomicValue, state *matchState, path string, depth int) (bool, error) {
+	property, err := evaluator.Evaluate(name)
 	if err != nil {
 		return false, err
 	}
+
 	if property == nil {
 		return false, nil
 	}
 
-	propertyValue := reflect.ValueOf(property)
-	kind := propertyValue.Kind()
-	if kind != reflect.Slice {
-		return false, errors.New("property " + strings.Join(prop.Name, ".") + " is expected to be a slice")
+	return matchFreeTextProperty(evaluator, name, property, atomic, state, path, depth)
+}
+
+// matchFreeTextProperty matches a field-less term with a property which is not nil.
+// Path is the dotted path of the property, which is empty if the expression has no schema.
+func matchFreeTextProperty(evaluator Evaluator, name string, property interface{}, atomic *atomicValue, state *matchState, path string, depth int) (bool, error) {
+	if !isNestedValue(property) || atomic.hasTypeHandler(property) {
+		return matchFreeTextValue(property, state.schemaValue(atomic, path)), nil
 	}
 
-	sliceLen := propertyValue.Len()
+	// Values the evaluator cannot walk into, such as maps of strings in a MapEvaluator, don't match.
+	subEvaluator, err := evaluator.GetSubEvaluator(name)
+	if err != nil || subEvaluator == nil {
+		return false, nil
+	}
+
+	return matchNestedFreeText(subEvaluator, atomic, state, path+".", depth)
+}
+
+// matchNestedFreeText matches a field-less term with all fields of a nested object or of every object of a slice.
+func matchNestedFreeText(subEvaluator Evaluator, atomic *atomicValue, state *matchState, prefix string, depth int) (bool, error) {
+	if subEvaluator.GetEvaluatorKind() == EvaluatorKindObject {
+		return matchAnyField(subEvaluator, atomic, state, prefix, depth+1)
+	}
+
+	sliceEvals, err := subEvaluator.GetArraySubEvaluators()
+	if err != nil {
+		return false, err
+	}
+
+	for _, ev := range sliceEvals {
+		res, err := matchAnyField(ev, atomic, state, prefix, depth+1)
+		if err != nil {
+			return false, err
+		}
+		if res {
+			return true, nil
+		}
+	}
 
-	for _, item := range prop.AndValues {
-		itemFound := false
+	return false, nil
+}
+
+// matchFreeTextValue compares a field-less term with a scalar property or a slice of scalars.
+// A term that cannot be converted to the property type simply does not match it.
+func matchFreeTextValue(property interface{}, atomic *atomicValue) bool {
+	propertyValue := reflect.ValueOf(property)
+	if atomic.isSliceProperty(property) {
+		sliceLen := propertyValue.Len()
 		for i := 0; i < sliceLen; i++ {
-			sliceItem := propertyValue.Index(i).Interface()
-			itemValue, err := compare(sliceItem, &item, equalCmp{})
-			if err != nil {
-				return false, err
-			}
-			if itemValue {
-				itemFound = true
-				break
+			res, err := compare(propertyValue.Index(i).Interface(), atomic, equalCmp{})
+			if err == nil && res {
+				return true
 			}
 		}
+		return false
+	}
 
-		if !itemFound {
-			return false, nil
+	res, err := compare(property, atomic, equalCmp{})
+	return err == nil && res
+}
+
+// isNestedValue reports whether the property holds an object or a slice of objects
+// which have to be searched through a sub evaluator.
+func isNestedValue(property interface{}) bool {
+	if property == nil {
+		return false
+	}
+
+	propertyType := reflect.TypeOf(property)
+	switch propertyType.Kind() {
+	case reflect.Map:
+		return true
+	case reflect.Struct:
+		return propertyType != reflect.TypeOf(time.Time{})
+	case reflect.Slice:
+		elemType := propertyType.Elem()
+		switch elemType.Kind() {
+		case reflect.Map:
+			return true
+		case reflect.Struct:
+			return elemType != reflect.TypeOf(time.Time{})
+		case reflect.Ptr:
+			return elemType.Elem().Kind() == reflect.Map ||
+				(elemType.Elem().Kind() == reflect.Struct && elemType.Elem() != reflect.TypeOf(time.Time{}))
+		case reflect.Interface:
+			propertyValue := reflect.ValueOf(property)
+			return propertyValue.Len() > 0 && isNestedValue(propertyValue.Index(0).Interface())
 		}
 	}
 
-	return true, nil
+	return false
 }
 
-func (se subExpression) match(evaluator Evaluator) (bool, error) {
-	var seValue bool
-	var err error
-	if se.SubExpression != nil {
-		seValue, err = se.SubExpression.match(evaluator)
+// match evaluates a value list against a property. Every value of the list is compared with the property
+// as a single value would be, so for slice properties `(a and b)` means that the slice contains both a and b.
+func (d valueDisjunction) match(property interface{}, operation string, state *matchState) (bool, error) {
+	if state.tracer != nil && len(d.RightValues) > 0 {
+		state.tracer.begin(&Explanation{Node: d.node()})
+		return state.tracer.end(d.matchOperands(property, operation, state))
+	}
+	return d.matchOperands(property, operation, state)
+}
+
+func (d valueDisjunction) matchOperands(property interface{}, operation string, state *matchState) (bool, error) {
+	result, err := d.LeftValue.match(property, operation, state)
+	if err != nil {
+		return false, err
+	}
+
+	for _, right := range d.RightValues {
+		if result {
+			return true, nil
+		}
+
+		result, err = right.match(property, operation, state)
 		if err != nil {
 			return false, err
 		}
-	} else {
-		seValue, err = se.Value.match(evaluator)
+	}
+
+	return result, nil
+}
+
+func (c valueConjunction) match(property interface{}, operation string, state *matchState) (bool, error) {
+	if state.tracer != nil && len(c.RightValues) > 0 {
+		state.tracer.begin(&Explanation{Node: c.node()})
+		return state.tracer.end(c.matchOperands(property, operation, state))
+	}
+	return c.matchOperands(property, operation, state)
+}
+
+func (c valueConjunction) matchOperands(property interface{}, operation string, state *matchState) (bool, error) {
+	result, err := c.LeftValue.match(property, operation, state)
+	if err != nil {
+		return false, err
+	}
+
+	for _, right := range c.RightValues {
+		if !result {
+			return false, nil
+		}
+
+		result, err = right.match(property, operation, state)
 		if err != nil {
 			return false, err
 		}
 	}
 
-	if se.IsInverted {
-		return !seValue, nil
+	return result, nil
+}
+
+func (v valueTerm) match(property interface{}, operation string, state *matchState) (bool, error) {
+	if v.IsInverted && state.tracer != nil {
+		state.tracer.begin(&Explanation{Node: v.node()})
+	}
+
+	var result bool
+	var err error
+	if v.ValueList != nil {
+		result, err = v.ValueList.match(property, operation, state)
+	} else {
+		result, err = matchAtomicValue(property, v.Value, operation, state)
+	}
+	if !v.IsInverted {
+		return result, err
+	}
+	if err != nil {
+		return state.tracer.end(false, err)
+	}
+
+	return state.tracer.end(!result, nil)
+}
+
+func (se subExpression) match(evaluator Evaluator, state *matchState) (bool, error) {
+	if se.IsInverted && state.tracer != nil {
+		state.tracer.begin(&Explanation{Node: se.node()})
+	}
+
+	var seValue bool
+	var err error
+	if se.SubExpression != nil {
+		seValue, err = se.SubExpression.match(evaluator, state)
+	} else if se.FreeText != nil {
+		seValue, err = matchFreeText(evaluator, se.FreeText, state)
 	} else {
-		return seValue, nil
+		seValue, err = se.Value.match(evaluator, state)
+	}
+	if !se.IsInverted {
+		return seValue, err
+	}
+	if err != nil {
+		return state.tracer.end(false, err)
 	}
+
+	return state.tracer.end(!seValue, nil)
 }
 
-func (c conjunction) match(evaluator Evaluator) (bool, error) {
-	result, err := c.LeftValue.match(evaluator)
+func (c conjunction) match(evaluator Evaluator, state *matchState) (bool, error) {
+	if state.tracer != nil && len(c.RightValues) > 0 {
+		state.tracer.begin(&Explanation{Node: c.node()})
+		return state.tracer.end(c.matchOperands(evaluator, state))
+	}
+	return c.matchOperands(evaluator, state)
+}
+
+func (c conjunction) matchOperands(evaluator Evaluator, state *matchState) (bool, error) {
+	result, err := c.LeftValue.match(evaluator, state)
 	if err != nil {
 		return false, err
 	}
@@ -245,9 +612,12 @@ func (c conjunction) match(evaluator Evaluator) (bool, error) {
 		if !result {
 			return false, nil
 		}
+		if err := state.done(); err != nil {
+			return false, err
+		}
 
 		var rightResult bool
-		rightResult, err := right.match(evaluator)
+		rightResult, err := right.match(evaluator, state)
 		if err != nil {
 			return false, err
 		}
@@ -258,8 +628,16 @@ func (c conjunction) match(evaluator Evaluator) (bool, error) {
 	return result, nil
 }
 
-func (d disjunction) match(evaluator Evaluator) (bool, error) {
-	result, err := d.LeftValue.match(evaluator)
+func (d disjunction) match(evaluator Evaluator, state *matchState) (bool, error) {
+	if state.tracer != nil && len(d.RightValues) > 0 {
+		state.tracer.begin(&Explanation{Node: d.node()})
+		return state.tracer.end(d.matchOperands(evaluator, state))
+	}
+	return d.matchOperands(evaluator, state)
+}
+
+func (d disjunction) matchOperands(evaluator Evaluator, state *matchState) (bool, error) {
+	result, err := d.LeftValue.match(evaluator, state)
 	if err != nil {
 		return false, err
 	}
@@ -268,9 +646,12 @@ func (d disjunction) match(evaluator Evaluator) (bool, error) {
 		if result {
 			return true, nil
 		}
+		if err := state.done(); err != nil {
+			return false, err
+		}
 
 		var rightResult bool
-		rightResult, err := right.match(evaluator)
+		rightResult, err := right.match(evaluator, state)
 		if err != nil {
 			return false, err
 		}
@@ -281,8 +662,8 @@ func (d disjunction) match(evaluator Evaluator) (bool, error) {
 	return result, nil
 }
 
-func (e expression) match(evaluator Evaluator) (bool, error) {
-	return e.Expr.match(evaluator)
+func (e expression) match(evaluator Evaluator, state *matchState) (bool, error) {
+	return e.Expr.match(evaluator, state)
 }
 
 type comparer interface {
@@ -319,55 +700,230 @@ func (lessOrEqualCmp) compare(handler typeHandler, left interface{}, right inter
 	return handler.lessOrEqual(left, right)
 }
 
+func operationComparer(operation string) comparer {
+	switch operation {
+	case ":":
+		return equalCmp{}
+	case ">":
+		return greaterCmp{}
+	case ">=":
+		return greaterOrEqualCmp{}
+	case "<":
+		return lessCmp{}
+	case "<=":
+		return lessOrEqualCmp{}
+	default:
+		panic("unknown operation " + operation)
+	}
+}
+
 func compare(property interface{}, atomic *atomicValue, comparer comparer) (bool, error) {
+	return compareWithConvertedType(convertProperty(property, atomic), atomic, comparer)
+}
+
+// compare compares a scalar property with the value and describes the comparison in the current explanation when tracing.
+func (state *matchState) compare(property interface{}, atomic *atomicValue, comparer comparer) (bool, error) {
+	if state.tracer == nil {
+		return compare(property, atomic, comparer)
+	}
+
+	converted := convertProperty(property, atomic)
+	state.tracer.comparison(property, converted, atomic, comparer)
+	return compareWithConvertedType(converted, atomic, comparer)
+}
+
+// convertProperty converts a property value to the type its comparer is created for.
+func convertProperty(property interface{}, atomic *atomicValue) interface{} {
+	if atomic.hasTypeHandler(property) {
+		return property
+	}
+	if isIPValue(property, atomic) {
+		return ipAddr(property)
+	}
+
 	switch v := property.(type) {
 	case int:
-		return compareWithConvertedType(int64(v), atomic, comparer)
+		return int64(v)
 	case int8:
-		return compareWithConvertedType(int64(v), atomic, comparer)
+		return int64(v)
 	case int16:
-		return compareWithConvertedType(int64(v), atomic, comparer)
+		return int64(v)
 	case int32:
-		return compareWithConvertedType(int64(v), atomic, comparer)
+		return int64(v)
 	case uint:
-		return compareWithConvertedType(uint64(v), atomic, comparer)
+		return uint64(v)
 	case uint8:
-		return compareWithConvertedType(uint64(v), atomic, comparer)
+		return uint64(v)
 	case uint16:
-		return compareWithConvertedType(uint64(v), atomic, comparer)
+		return uint64(v)
 	case uint32:
-		return compareWithConvertedType(uint64(v), atomic, comparer)
+		return uint64(v)
 	case float32:
-		return compareWithConvertedType(float64(v), atomic, comparer)
-
+		return float64(v)
+	case string, int64, uint64, float64, bool, time.Time, time.Duration:
+		return property
 	default:
-		return compareWithConvertedType(property, atomic, comparer)
+		return underlyingValue(property)
 	}
 }
 
 func compareWithConvertedType(property interface{}, atomic *atomicValue, comparer comparer) (bool, error) {
-	if atomic.wildcard.firstStar && atomic.wildcard.lastStar && len(atomic.wildcard.parts) == 0 {
+	if atomic.wildcard.matchesAll() {
 		return true, nil
 	}
 
-	propertyType := reflect.TypeOf(property)
-	if atomic.comparer == nil || atomic.valueType != propertyType {
-		var err error
-		atomic.comparer, err = createComparer(property, atomic, comparer)
-		if err != nil {
-			return false, err
-		}
+	typed, err := atomic.typedComparer(property, comparer)
+	if err != nil {
+		return false, err
+	}
+	return typed.match(property), nil
+}
+
+// typedComparer returns the comparer of the value for the type of the converted property,
+// creating it on the first use.
+func (atomic *atomicValue) typedComparer(property interface{}, comparer comparer) (*typedComparer, error) {
+	if atomic.comparers == nil {
+		return createComparer(property, atomic, comparer)
+	}
+
+	key := comparerKey{reflect.TypeOf(property), comparer}
+	if cached, ok := atomic.comparers.entries.Load(key); ok {
+		return cached.(*typedComparer), nil
+	}
+
+	created, err := createComparer(property, atomic, comparer)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, _ := atomic.comparers.entries.LoadOrStore(key, created)
+	return cached.(*typedComparer), nil
+}
+
+// withSchema returns a copy of the value bound to the schema of a property resolved while matching.
+// Copies are kept per property path, so their comparers are created once.
+func (atomic *atomicValue) withSchema(path string, field FieldSchema) *atomicValue {
+	if atomic.comparers == nil {
+		return atomic
+	}
+	if bound, ok := atomic.comparers.fields.Load(path); ok {
+		return bound.(*atomicValue)
+	}
+
+	bound := *atomic
+	bound.comparers = &comparerCache{
+		handlers: atomic.comparers.handlers,
+		field:    field,
+		clock:    atomic.comparers.clock,
+		strings:  atomic.comparers.strings,
+	}
+	cached, _ := atomic.comparers.fields.LoadOrStore(path, &bound)
+	return cached.(*atomicValue)
+}
+
+type comparerKey struct {
+	valueType reflect.Type
+	comparer  comparer
+}
+
+// comparerCache keeps comparers created for an atomic value per property type and operation.
+// The AST itself is never modified during matching, so a parsed expression can be shared between goroutines.
+type comparerCache struct {
+	entries sync.Map
+	// fields keeps copies of the value bound to the schema of properties resolved while matching by their path.
+	fields   sync.Map
+	handlers TypeHandlers
+	field    FieldSchema
+	clock    func() time.Time
+	strings  StringMatching
+}
+
+// stringMatching returns the string matching of the value: the one of its property in the schema
+// if it is set or the one of the expression otherwise.
+func (c *comparerCache) stringMatching() StringMatching {
+	if c == nil {
+		return 0
+	}
+
+	matching := c.strings
+	if c.field.StringMatching != 0 {
+		matching = c.field.StringMatching
+	}
+	if matching&ExactStrings != 0 {
+		return 0
+	}
+	return matching
+}
+
+func (c *comparerCache) timeClock() func() time.Time {
+	if c == nil {
+		return nil
+	}
+	return c.clock
+}
+
+func (c *comparerCache) fieldSchema() FieldSchema {
+	if c == nil {
+		return FieldSchema{}
+	}
+	return c.field
+}
+
+func (c *comparerCache) typeHandler(valueType reflect.Type) (TypeHandler, bool) {
+	if c == nil || len(c.handlers) == 0 {
+		return nil, false
+	}
+	handler, ok := c.handlers[valueType]
+	return handler, ok
+}
 
-		atomic.valueType = propertyType
+// isSliceProperty reports whether the elements of a slice property are compared with the value one by one.
+// Slices compared as a single value, such as net.IP or types with a type handler, are not.
+func (atomic *atomicValue) isSliceProperty(property interface{}) bool {
+	if reflect.ValueOf(property).Kind() != reflect.Slice {
+		return false
 	}
+	return reflect.TypeOf(property) != netIPType && !atomic.hasTypeHandler(property)
+}
+
+// hasTypeHandler reports whether a custom type handler is registered for the type of the property.
+func (atomic *atomicValue) hasTypeHandler(property interface{}) bool {
+	_, ok := atomic.comparers.typeHandler(reflect.TypeOf(property))
+	return ok
+}
+
+// typedComparer compares property values of a single type with the query value converted to that type.
+type typedComparer struct {
+	handler      typeHandler
+	requestValue interface{}
+	comparer     comparer
+}
 
-	return atomic.comparer(property), nil
+func (c *typedComparer) match(propertyValue interface{}) bool {
+	return c.comparer.compare(c.handler, propertyValue, c.requestValue)
 }
 
-func createComparer(propertyValue interface{}, atomic *atomicValue, comparer comparer) (func(propertyValue interface{}) bool, error) {
+func createComparer(propertyValue interface{}, atomic *atomicValue, comparer comparer) (*typedComparer, error) {
+	if atomic.regexp != nil {
+		return createRegexpComparer(propertyValue, atomic, comparer)
+	}
+	if handler, ok := atomic.comparers.typeHandler(reflect.TypeOf(propertyValue)); ok {
+		return createComparerForHandler(customTypeHandler{handler}, propertyValue, atomic, comparer)
+	}
+
+	switch propertyValue.(type) {
+	case int64, uint64, float64:
+		if atomic.wildcard.isPattern() {
+			return createComparerForHandler(numberPatternTypeHandler{}, propertyValue, atomic, comparer)
+		}
+	}
+
 	switch propertyValue.(type) {
 	case string:
-		return createComparerForHandler(stringTypeHandler{atomic.wildcard}, propertyValue, atomic, comparer)
+		if atomic.comparers.fieldSchema().Type == FieldText {
+			return createComparerForHandler(newTextTypeHandler(atomic), propertyValue, atomic, comparer)
+		}
+		return createComparerForHandler(newStringTypeHandler(atomic), propertyValue, atomic, comparer)
 	case int64:
 		return createComparerForHandler(int64TypeHandler{}, propertyValue, atomic, comparer)
 	case uint64:
@@ -377,25 +933,29 @@ func createComparer(propertyValue interface{}, atomic *atomicValue, comparer com
 	case bool:
 		return createComparerForHandler(boolTypeHandler{}, propertyValue, atomic, comparer)
 	case time.Time:
-		return createComparerForHandler(timeTypeHandler{}, propertyValue, atomic, comparer)
+		return createComparerForHandler(timeTypeHandler{atomic.comparers.timeClock()}, propertyValue, atomic, comparer)
 	case time.Duration:
 		return createComparerForHandler(durationTypeHandler{}, propertyValue, atomic, comparer)
+	case netip.Addr:
+		return createComparerForHandler(ipTypeHandler{atomic.wildcard}, propertyValue, atomic, comparer)
 	}
 
-	return nil, errors.New("unsupported property type " + reflect.TypeOf(propertyValue).Name())
+	return nil, fmt.Errorf("unsupported property type %s", reflect.TypeOf(propertyValue))
 }
 
 func createComparerForHandler(
 	handler typeHandler,
 	propertyValue interface{},
 	atomic *atomicValue,
-	comparer comparer) (func(propertyValue interface{}) bool, error) {
+	comparer comparer) (*typedComparer, error) {
 	requestValue, err := handler.convert(atomic.Value)
 	if err != nil {
 		return nil, err
 	}
 
-	return func(propertyValue interface{}) bool {
-		return comparer.compare(handler, propertyValue, requestValue)
+	return &typedComparer{
+		handler:      handler,
+		requestValue: requestValue,
+		comparer:     comparer,
 	}, nil
 }
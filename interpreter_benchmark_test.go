@@ -1,6 +1,7 @@
 package gokql
 
 import (
+	"encoding/json"
 	"testing"
 )
 
@@ -30,3 +31,72 @@ func BenchmarkMatch(b *testing.B) {
 		expr.Match(evaluator)
 	}
 }
+
+var structExpr = func() Expression {
+	expr, err := Parse("Pstring:val* and (Pint>1 or Pfloat64<='2.5') and not Pbool:true")
+	if err != nil {
+		panic(err)
+	}
+	return expr
+}()
+
+var structItem = testStruct{Pstring: "value", Pint: 2}
+
+func BenchmarkReflectMatch(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		structExpr.Match(NewReflectEvaluator(structItem))
+	}
+}
+
+func BenchmarkCompiledMatch(b *testing.B) {
+	match, err := CompileFor[testStruct](structExpr)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		match(&structItem)
+	}
+}
+
+var logLine = []byte(`{"@timestamp":"2024-03-05T10:20:30Z","level":"error","status":503,"duration":12.5,` +
+	`"message":"upstream connect error or disconnect/reset before headers","request":{"method":"GET",` +
+	`"path":"/api/v1/orders","headers":{"user-agent":"curl/8.4.0","accept":"*/*"}},` +
+	`"host":{"name":"web-1","ip":"10.1.2.3","tags":["prod","eu"]},"trace":{"id":"4bf92f3577b34da6","span":"00f067aa0ba902b7"}}`)
+
+var logExpr = func() Expression {
+	expr, err := Parse("level:error and status>=500 and host:{name:web*} and request.method:GET")
+	if err != nil {
+		panic(err)
+	}
+	return expr
+}()
+
+func BenchmarkJSONEvaluatorMatch(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		evaluator, err := NewJSONEvaluator(logLine)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if matched, err := logExpr.Match(evaluator); err != nil || !matched {
+			b.Fatal(matched, err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalMapEvaluatorMatch(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(logLine, &obj); err != nil {
+			b.Fatal(err)
+		}
+		evaluator, err := NewMapEvaluator(obj)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if matched, err := logExpr.Match(evaluator); err != nil || !matched {
+			b.Fatal(matched, err)
+		}
+	}
+}
@@ -107,7 +107,7 @@ func TestBasicMatch(t *testing.T) {
 		map[string]interface{}{
 			"propStr": "value1",
 		},
-		true)
+		false)
 
 	testExprMap(
 		t,
@@ -144,6 +144,91 @@ func TestBasicMatch(t *testing.T) {
 		true)
 }
 
+func TestFreeText(t *testing.T) {
+	obj := map[string]any{
+		"level":   "error",
+		"message": "connection refused",
+		"code":    503,
+		"tags":    []string{"db", "timeout"},
+		"host": map[string]any{
+			"name": "web_1",
+		},
+		"events": []any{
+			map[string]any{"kind": "restart"},
+		},
+		"labels": map[string]string{"app": "web"},
+		"ports":  map[string]int{"http": 8080},
+	}
+
+	testExprMap(t, "error", obj, true)
+	testExprMap(t, "warning", obj, false)
+	testExprMap(t, "\"connection refused\"", obj, true)
+	testExprMap(t, "connection*", obj, true)
+	testExprMap(t, "503", obj, true)
+	testExprMap(t, "timeout", obj, true)
+	testExprMap(t, "web_1", obj, true)
+	testExprMap(t, "restart", obj, true)
+	testExprMap(t, "error and not code:200", obj, true)
+	testExprMap(t, "not error", obj, false)
+	testExprMap(t, "web", obj, false)
+	testExprMap(t, "8080 or restart", obj, true)
+
+	testFreeText := func(query string, defaultFields []string, expected bool) {
+		t.Helper()
+		ev, err := NewMapEvaluator(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expr, err := ParseWithOptions(query, ParseOptions{DefaultFields: defaultFields})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := expr.Match(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != expected {
+			t.Errorf("Unexpected match result: %v for expression %s with default fields %v", result, query, defaultFields)
+		}
+	}
+
+	testFreeText("error", []string{"message"}, false)
+	testFreeText("error", []string{"message", "level"}, true)
+	testFreeText("web_1", []string{"host.name"}, true)
+	testFreeText("web_1", []string{"host"}, true)
+	testFreeText("web_1", []string{"notexisted.name"}, false)
+	testFreeText("web", []string{"labels"}, false)
+
+	expr, err := Parse("error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := NewMapEvaluator(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := expr.WithDefaultFields("message").Match(ev); err != nil || result {
+		t.Errorf("Unexpected match result with default fields set at match time: %v, %v", result, err)
+	}
+
+	type nested struct {
+		Name string
+	}
+	item := struct {
+		Level  string
+		Count  int
+		Nested nested
+		hidden string
+	}{"info", 3, nested{"inner"}, "error"}
+
+	testExpr(t, "info", NewReflectEvaluator(item), true)
+	testExpr(t, "3", NewReflectEvaluator(item), true)
+	testExpr(t, "inner", NewReflectEvaluator(item), true)
+	testExpr(t, "error", NewReflectEvaluator(item), false)
+}
+
 func TestEmptyCheck(t *testing.T) {
 	obj := map[string]any{
 		"prop": map[string]any{
@@ -156,6 +241,52 @@ func TestEmptyCheck(t *testing.T) {
 	testExprMap(t, "arr:*", obj, true)
 }
 
+func TestExistence(t *testing.T) {
+	type custom struct{ A, B int }
+	var nilPointer *int
+	obj := map[string]any{
+		"struct":      custom{1, 2},
+		"object":      map[string]any{},
+		"emptySlice":  []string{},
+		"nilSlice":    []string(nil),
+		"nilMap":      map[string]any(nil),
+		"nilPointer":  nilPointer,
+		"null":        nil,
+		"empty":       "",
+		"zero":        0,
+		"nulls":       []any{nil, nil},
+		"blanks":      []string{"", ""},
+		"nested":      map[string]any{"empty": ""},
+		"objects":     []map[string]any{{"a": 1}},
+		"unsupported": make(chan int),
+	}
+
+	existing := []string{"struct", "object", "emptySlice", "empty", "zero", "nulls", "blanks", "nested.empty", "objects", "unsupported"}
+	missing := []string{"nilSlice", "nilMap", "nilPointer", "null", "absent", "nested.absent", "absent.empty"}
+	for _, field := range existing {
+		testExprMap(t, field+":*", obj, true)
+		testExprMap(t, "not "+field+":*", obj, false)
+		testExprMap(t, field+":(* or x)", obj, true)
+	}
+	for _, field := range missing {
+		testExprMap(t, field+":*", obj, false)
+		testExprMap(t, "not "+field+":*", obj, true)
+		testExprMap(t, "not "+field+":x", obj, true)
+	}
+
+	policy := ParseOptions{Missing: MissingPolicy{EmptyStrings: true, EmptyCollections: true}}
+	for _, field := range []string{"object", "emptySlice", "empty", "nulls", "blanks", "nested.empty"} {
+		testExprWithOptions(t, field+":*", policy, obj, false)
+		testExprWithOptions(t, "not "+field+":*", policy, obj, true)
+	}
+	testExprWithOptions(t, `not empty:""`, policy, obj, true)
+	testExprWithOptions(t, "struct:* and zero:* and objects:*", policy, obj, true)
+
+	testExprMap(t, `empty:""`, obj, true)
+	testExprMap(t, `empty:"*"`, obj, false)
+	testExprMap(t, "object:{*}", obj, false)
+}
+
 func TestArrays(t *testing.T) {
 	obj := map[string]any{
 		"level1": map[string]any{
@@ -231,13 +362,78 @@ func TestArrays(t *testing.T) {
 
 	testExprMap(
 		t,
-		"prop:'a*'",
+		"prop:a*",
 		map[string]interface{}{
 			"prop": []string{"bbb", "abc", "ccc"},
 		},
 		true)
 }
 
+func TestSliceRange(t *testing.T) {
+	base := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	slices := map[string]interface{}{
+		"int":      []int{50, 150},
+		"int8":     []int8{50, 120},
+		"int16":    []int16{50, 150},
+		"int32":    []int32{50, 150},
+		"int64":    []int64{50, 150},
+		"uint":     []uint{50, 150},
+		"uint8":    []uint8{50, 150},
+		"uint16":   []uint16{50, 150},
+		"uint32":   []uint32{50, 150},
+		"uint64":   []uint64{50, 150},
+		"float32":  []float32{50.5, 150.5},
+		"float64":  []float64{50.5, 150.5},
+		"duration": []time.Duration{50 * time.Millisecond, 150 * time.Millisecond},
+		"time":     []time.Time{base, base.Add(48 * time.Hour)},
+	}
+
+	values := map[string][]string{
+		"duration": {"100ms", "10ms", "200ms", "50ms"},
+		"time":     {"'2022-01-02T00:00:00Z'", "'2021-12-31T00:00:00Z'", "'2022-01-05T00:00:00Z'", "'2022-01-01T00:00:00Z'"},
+	}
+
+	for name, slice := range slices {
+		// middle value, value below all elements, value above all elements, the smallest element
+		vals, ok := values[name]
+		if !ok {
+			vals = []string{"100", "10", "200", "50"}
+			if name == "float32" || name == "float64" {
+				vals[3] = "'50.5'"
+			}
+		}
+		middle, below, above, smallest := vals[0], vals[1], vals[2], vals[3]
+
+		testAny := func(query string, expected bool) {
+			t.Helper()
+			testExprMap(t, query, map[string]interface{}{"p": slice}, expected)
+		}
+		testAll := func(query string, expected bool) {
+			t.Helper()
+			testExprWithOptions(t, query, ParseOptions{RangeQuantifier: AllElements}, map[string]interface{}{"p": slice}, expected)
+		}
+
+		testAny("p>"+middle, true)
+		testAny("p<"+middle, true)
+		testAny("p>"+above, false)
+		testAny("p<"+below, false)
+		testAny("p>="+above, false)
+		testAny("p<="+smallest, true)
+		testAny("p:"+middle, false)
+
+		testAll("p>"+middle, false)
+		testAll("p>"+below, true)
+		testAll("p<"+above, true)
+		testAll("p>="+smallest, true)
+		testAll("p>"+smallest, false)
+		testAll("p<="+middle, false)
+		testAll("p:"+smallest, true)
+	}
+
+	testExprWithOptions(t, "p>1", ParseOptions{RangeQuantifier: AllElements}, map[string]interface{}{"p": []int{}}, false)
+	testExprMap(t, "p>1", map[string]interface{}{"p": []int{}}, false)
+}
+
 func TestReflectMatch(t *testing.T) {
 	type nested struct {
 		NestedProp  string
@@ -338,6 +534,47 @@ func TestDuration(t *testing.T) {
 	testType(t, "Pduration", "300ms", "400ms", "200ms", testStruct{Pduration: getDuration("300ms")})
 }
 
+func TestValueLists(t *testing.T) {
+	ts := time.Date(2021, 5, 17, 1, 0, 0, 0, time.UTC)
+	obj := testStruct{
+		Pint:      200,
+		Pint64:    2,
+		Puint64:   3,
+		Pfloat64:  0.75,
+		Pfloat32:  0.5,
+		Pbool:     true,
+		Pstring:   "302",
+		Ptime:     ts,
+		Pduration: 5 * time.Second,
+	}
+
+	test(t, "Pint64:(1 or 2)", true, obj)
+	test(t, "Pint64:(1 or 3)", false, obj)
+	test(t, "Puint64:(3 or 4)", true, obj)
+	test(t, "Pfloat64:(0.5 or 0.75)", true, obj)
+	test(t, "Pfloat32:(0.25 or 0.75)", false, obj)
+	test(t, "Pfloat32:0.5 and Pfloat64>0.5 and Pfloat64<+1 and not Pfloat64<-1.5", true, obj)
+	test(t, "Pbool:(true or false)", true, obj)
+	test(t, "Pbool:(false or false)", false, obj)
+	test(t, "Ptime:('2021-05-17T01:00:00Z' or '2022-01-01T00:00:00Z')", true, obj)
+	test(t, "Pduration:(1s or 5s)", true, obj)
+	test(t, "Pduration>(10s or 1s)", true, obj)
+	test(t, "Pduration>(10s and 1s)", false, obj)
+
+	test(t, "Pint:(200 or (3* and not 304))", true, obj)
+	test(t, "Pint:(3* and not 304)", false, obj)
+	test(t, "Pint:(200 or (3* and not 304))", false, testStruct{Pint: 301})
+	test(t, "Pstring:(200 or (3* and not 304))", true, obj)
+	test(t, "Pstring:(200 or (3* and not 302))", false, obj)
+	test(t, "Pstring:(not 302)", false, obj)
+	test(t, "Pstring:(not (1* or 2*))", true, obj)
+
+	testExprMap(t, "prop:(1 and not 7)", map[string]interface{}{"prop": []int{0, 1, 5}}, true)
+	testExprMap(t, "prop:(1 and not 5)", map[string]interface{}{"prop": []int{0, 1, 5}}, false)
+	testExprMap(t, "prop:(1 and not (0 and 5))", map[string]interface{}{"prop": []int{0, 1, 5}}, false)
+	testExprMap(t, "prop:(0.5 or 0.75)", map[string]interface{}{"prop": []float64{0.25, 0.75}}, true)
+}
+
 func TestString(t *testing.T) {
 	testType(t, "Pstring", "2", "3", "1", testStruct{Pstring: "2"})
 }
@@ -374,6 +611,28 @@ func testExprMap(t *testing.T, expression string, obj map[string]interface{}, ex
 	testExpr(t, expression, ev, expectedResult)
 }
 
+func testExprWithOptions(t *testing.T, expression string, options ParseOptions, obj map[string]interface{}, expectedResult bool) {
+	t.Helper()
+	ev, err := NewMapEvaluator(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expr, err := ParseWithOptions(expression, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := expr.Match(ev)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if result != expectedResult {
+		t.Errorf("Unexpected match result: %v for expression %s", result, expression)
+	}
+}
+
 func test(t *testing.T, expression string, expectedResult bool, obj testStruct) {
 	testExpr(t, expression, NewReflectEvaluator(obj), expectedResult)
 }
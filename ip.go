@@ -0,0 +1,120 @@
+package gokql
+
+import (
+	"net"
+	"net/netip"
+	"reflect"
+	"strings"
+)
+
+var netIPType = reflect.TypeOf(net.IP(nil))
+
+// ipQuery is a query value compared with IP addresses: a single address,
+// a CIDR network matching every address it contains, or a wildcard pattern.
+type ipQuery struct {
+	first   netip.Addr
+	last    netip.Addr
+	pattern bool
+}
+
+// ================ IP  ====================
+
+type ipTypeHandler struct {
+	wildcard wildcard
+}
+
+func (h ipTypeHandler) convert(value string) (result interface{}, err error) {
+	if h.wildcard.isPattern() {
+		return ipQuery{pattern: true}, nil
+	}
+
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, err
+		}
+		prefix = prefix.Masked()
+		return ipQuery{first: prefix.Addr(), last: lastAddr(prefix)}, nil
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return nil, err
+	}
+	addr = addr.Unmap()
+	return ipQuery{first: addr, last: addr}, nil
+}
+
+func (h ipTypeHandler) equal(left interface{}, right interface{}) bool {
+	addr, query := left.(netip.Addr), right.(ipQuery)
+	if !addr.IsValid() {
+		return false
+	}
+	if query.pattern {
+		return h.wildcard.Match(addr.String())
+	}
+	return sameFamily(addr, query) && query.first.Compare(addr) <= 0 && addr.Compare(query.last) <= 0
+}
+
+func (h ipTypeHandler) greater(left interface{}, right interface{}) bool {
+	addr, query := left.(netip.Addr), right.(ipQuery)
+	return sameFamily(addr, query) && addr.Compare(query.last) > 0
+}
+
+func (h ipTypeHandler) less(left interface{}, right interface{}) bool {
+	addr, query := left.(netip.Addr), right.(ipQuery)
+	return sameFamily(addr, query) && addr.Compare(query.first) < 0
+}
+
+func (h ipTypeHandler) greaterOrEqual(left interface{}, right interface{}) bool {
+	addr, query := left.(netip.Addr), right.(ipQuery)
+	return sameFamily(addr, query) && addr.Compare(query.first) >= 0
+}
+
+func (h ipTypeHandler) lessOrEqual(left interface{}, right interface{}) bool {
+	addr, query := left.(netip.Addr), right.(ipQuery)
+	return sameFamily(addr, query) && addr.Compare(query.last) <= 0
+}
+
+// sameFamily reports whether an address can be compared with the query value.
+// Wildcard patterns are never ordered and IPv4 addresses are not ordered against IPv6 ones.
+func sameFamily(addr netip.Addr, query ipQuery) bool {
+	return addr.IsValid() && !query.pattern && addr.Is4() == query.first.Is4()
+}
+
+// lastAddr returns the last address of a network.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(bytes)*8; bit++ {
+		bytes[bit/8] |= 1 << (7 - bit%8)
+	}
+	last, _ := netip.AddrFromSlice(bytes)
+	return last
+}
+
+// ipAddr converts a property value holding an IP address to a netip.Addr.
+// Values which are not valid addresses are converted to the zero Addr, which matches nothing.
+func ipAddr(property interface{}) netip.Addr {
+	var addr netip.Addr
+	switch v := property.(type) {
+	case netip.Addr:
+		addr = v
+	case net.IP:
+		addr, _ = netip.AddrFromSlice(v)
+	case string:
+		addr, _ = netip.ParseAddr(v)
+	}
+	return addr.Unmap()
+}
+
+// isIPValue reports whether the property holds an IP address, either natively
+// or as a string of a field declared as FieldIP in the schema.
+func isIPValue(property interface{}, atomic *atomicValue) bool {
+	switch property.(type) {
+	case netip.Addr, net.IP:
+		return true
+	case string:
+		return atomic.comparers.fieldSchema().Type == FieldIP
+	}
+	return false
+}
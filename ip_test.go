@@ -0,0 +1,195 @@
+package gokql
+
+import (
+	"encoding/json"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+type ipItem struct {
+	Client   net.IP
+	Server   netip.Addr
+	Source   string
+	Peers    []net.IP
+	Upstream []string
+}
+
+func TestIPMatch(t *testing.T) {
+	item := ipItem{
+		Client:   net.ParseIP("10.1.2.3"),
+		Server:   netip.MustParseAddr("2001:db8::1"),
+		Source:   "192.168.0.10",
+		Peers:    []net.IP{net.ParseIP("172.16.0.1"), net.ParseIP("8.8.8.8")},
+		Upstream: []string{"127.0.0.1", "::1"},
+	}
+	options := ParseOptions{
+		Schema: Schema{
+			"Source":   {Type: FieldIP},
+			"Upstream": {Type: FieldIP},
+		},
+	}
+
+	testIP := func(query string, expected bool) {
+		t.Helper()
+		expr, err := ParseWithOptions(query, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := expr.Match(NewReflectEvaluator(item))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != expected {
+			t.Errorf("Unexpected match result: %v for expression %s", res, query)
+		}
+
+		match, err := CompileFor[ipItem](expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res, err := match(&item); err != nil || res != expected {
+			t.Errorf("Unexpected compiled match result: %v, %v for expression %s", res, err, query)
+		}
+	}
+
+	testIP("Client:10.1.2.3", true)
+	testIP("Client:10.1.2.4", false)
+	testIP("Client:10.0.0.0/8", true)
+	testIP("Client:10.1.3.0/24", false)
+	testIP(`Client:10\.1\.*`, true)
+	testIP("Client>10.1.2.2", true)
+	testIP("Client>10.0.0.0/16", true)
+	testIP("Client<=10.0.0.0/8", true)
+	testIP("Client<10.0.0.0/8", false)
+	testIP("Client>=10.1.2.3 and Client<=10.1.2.3", true)
+	testIP("Client>'::1'", false)
+	testIP("Client:(192.168.0.0/16 or 10.0.0.0/8)", true)
+	testIP("Client:'::ffff:10.1.2.3'", true)
+
+	testIP(`Server:"2001:db8::/32"`, true)
+	testIP(`Server:"2001:db9::/32"`, false)
+	testIP(`Server:"2001:db8::1"`, true)
+	testIP("Server:10.0.0.0/8", false)
+
+	testIP("Source:192.168.0.0/16", true)
+	testIP("Source>192.168.0.9", true)
+	testIP(`Source:192\.168\.*`, true)
+	testIP("Peers:8.8.8.8", true)
+	testIP("Peers:172.16.0.0/12 and Peers:8.0.0.0/8", true)
+	testIP("Peers:192.168.0.0/16", false)
+	testIP(`Upstream:"::1"`, true)
+	testIP("Upstream:127.0.0.0/8", true)
+
+	testExprMap(t, "ip:10.0.0.0/8", map[string]interface{}{"ip": netip.MustParseAddr("10.2.3.4")}, true)
+	testExprMap(t, "ip:10.0.0.0/8", map[string]interface{}{"ip": "10.2.3.4"}, false)
+	testExprMap(t, "ip:10.2.3.4", map[string]interface{}{"ip": "10.2.3.4"}, true)
+	testExprWithOptions(t, "ip:10.0.0.0/8", options, map[string]interface{}{"Source": "not an ip"}, false)
+	testExprWithOptions(t, "a:{Source:192.168.0.0/16}", ParseOptions{Schema: Schema{"a.Source": {Type: FieldIP}}},
+		map[string]interface{}{"a": map[string]interface{}{"Source": "192.168.1.1"}}, true)
+
+	if _, err := Parse("Client:10.0.0.0/8 and Server>10.1.2.3"); err != nil {
+		t.Errorf("Unexpected error for unquoted IP literals: %v", err)
+	}
+
+	expr := mustParse(t, "Client:10.0.0.0/33")
+	if _, err := expr.Match(NewReflectEvaluator(item)); err == nil {
+		t.Error("Expected error for an invalid network")
+	}
+	if _, err := CompileFor[ipItem](expr); err == nil {
+		t.Error("Expected compile error for an invalid network")
+	}
+}
+
+type resolvedItem struct {
+	IP      string `json:"ip"`
+	Message string `json:"message"`
+	HTTP    struct {
+		Peer string `json:"peer"`
+	} `json:"http"`
+	Hosts []struct {
+		Addr string `json:"addr"`
+	} `json:"hosts"`
+}
+
+func TestSchemaOfResolvedFields(t *testing.T) {
+	item := resolvedItem{IP: "10.1.2.3", Message: "Connection refused by upstream"}
+	item.HTTP.Peer = "192.168.0.10"
+	item.Hosts = append(item.Hosts, struct {
+		Addr string `json:"addr"`
+	}{"172.16.0.1"})
+
+	data, _ := json.Marshal(item)
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		t.Fatal(err)
+	}
+	jsonEvaluator, err := NewJSONEvaluator(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluators := map[string]Evaluator{
+		"map":     mustMapEvaluator(t, obj),
+		"reflect": NewReflectEvaluator(item),
+		"json":    jsonEvaluator,
+	}
+
+	options := ParseOptions{
+		Schema: Schema{
+			"ip":         {Type: FieldIP},
+			"message":    {Type: FieldText},
+			"http.peer":  {Type: FieldIP},
+			"hosts.addr": {Type: FieldIP},
+		},
+	}
+	withDefaultFields := options
+	withDefaultFields.DefaultFields = []string{"ip", "message", "http.peer"}
+
+	for _, test := range []struct {
+		query    string
+		options  ParseOptions
+		expected bool
+	}{
+		{"10.0.0.0/8", withDefaultFields, true},
+		{"192.168.0.0/16", withDefaultFields, true},
+		{"refused", withDefaultFields, true},
+		{"172.16.0.0/12", withDefaultFields, false},
+		{"10.0.0.0/8", ParseOptions{DefaultFields: []string{"ip"}}, false},
+		{"10.0.0.0/8", options, true},
+		{"172.16.0.0/12", options, true},
+		{"refused", options, true},
+		{"11.0.0.0/8", options, false},
+		{"i*:10.0.0.0/8", options, true},
+		{"i*:11.0.0.0/8", options, false},
+		{"*.peer:192.168.0.0/16", options, true},
+		{"mess*:(refused and upstream)", options, true},
+		{"h*:{peer:192.168.0.0/16}", options, true},
+		{"h*:{addr:172.16.0.0/12}", options, true},
+		{"h*:{172.16.0.0/12}", options, true},
+		{"hosts:{a*:172.16.0.0/12}", options, true},
+		{"hosts:{a*:10.0.0.0/8}", options, false},
+	} {
+		expr, err := ParseWithOptions(test.query, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, evaluator := range evaluators {
+			if res, err := expr.Match(evaluator); err != nil || res != test.expected {
+				t.Errorf("Unexpected result %v, %v of %s evaluator for %s. Expected: %v", res, err, name, test.query, test.expected)
+			}
+			if explanation := expr.Explain(evaluator); explanation.Result != test.expected {
+				t.Errorf("Unexpected explanation of %s evaluator for %s:\n%s", name, test.query, explanation)
+			}
+		}
+
+		match, err := CompileFor[resolvedItem](expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res, err := match(&item); err != nil || res != test.expected {
+			t.Errorf("Unexpected compiled result %v, %v for %s. Expected: %v", res, err, test.query, test.expected)
+		}
+	}
+}
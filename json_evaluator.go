@@ -0,0 +1,370 @@
+package gokql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// JSONEvaluator evaluates properties of a JSON document directly from its bytes, without
+// unmarshalling it. Only the properties an expression refers to are decoded,
+// nested objects and arrays are evaluated by sub evaluators sharing the same bytes.
+//
+// Values are decoded like encoding/json decodes them into interface{}: strings to string,
+// booleans to bool and numbers to float64, so the evaluator matches the same as MapEvaluator
+// of an unmarshalled document. Null properties are treated as missing. If an object has
+// duplicate keys, the last one is used.
+//
+// The document is not validated up front: malformed JSON is reported as an error by the
+// evaluation which reaches it. The bytes must not be modified while the evaluator is in use.
+type JSONEvaluator struct {
+	data []byte
+	kind EvaluatorKind
+}
+
+// NewJSONEvaluator creates an evaluator of a JSON object or of a JSON array of objects.
+func NewJSONEvaluator(data []byte) (*JSONEvaluator, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("empty JSON document")
+	}
+
+	switch data[0] {
+	case '{':
+		return &JSONEvaluator{data: data, kind: EvaluatorKindObject}, nil
+	case '[':
+		return &JSONEvaluator{data: data, kind: EvaluatorKindSlice}, nil
+	default:
+		return nil, fmt.Errorf("unexpected JSON value %.20q: it should be an object or an array", data)
+	}
+}
+
+func (e *JSONEvaluator) Evaluate(propertyName string) (interface{}, error) {
+	raw, err := e.member(propertyName)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	return decodeJSONValue(raw)
+}
+
+func (e *JSONEvaluator) GetSubEvaluator(propertyName string) (Evaluator, error) {
+	raw, err := e.member(propertyName)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+
+	ev, err := NewJSONEvaluator(raw)
+	if err != nil {
+		return nil, fmt.Errorf("property %s: %w", propertyName, err)
+	}
+	return ev, nil
+}
+
+func (e *JSONEvaluator) GetEvaluatorKind() EvaluatorKind {
+	return e.kind
+}
+
+func (e *JSONEvaluator) GetArraySubEvaluators() ([]Evaluator, error) {
+	if e.kind != EvaluatorKindSlice {
+		return nil, fmt.Errorf("unsupported operation for kind %v", e.kind)
+	}
+
+	var res []Evaluator
+	var elementErr error
+	err := scanJSONArray(e.data, func(element []byte) bool {
+		if element[0] != '{' {
+			elementErr = fmt.Errorf("unexpected array item %.20q. It should be an object", element)
+			return false
+		}
+		res = append(res, &JSONEvaluator{data: element, kind: EvaluatorKindObject})
+		return true
+	})
+	if err == nil {
+		err = elementErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (e *JSONEvaluator) Keys() ([]string, error) {
+	if e.kind != EvaluatorKindObject {
+		return nil, nil
+	}
+
+	var keys []string
+	seen := map[string]bool{}
+	var decodeErr error
+	err := scanJSONObject(e.data, func(key []byte, value []byte) bool {
+		name, err := decodeJSONString(key)
+		if err != nil {
+			decodeErr = err
+			return false
+		}
+		if !seen[name] {
+			seen[name] = true
+			keys = append(keys, name)
+		}
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	return keys, err
+}
+
+// member returns the raw value of the property, or nil if the object has no such property
+// or it is null. The whole object is scanned, as the last of duplicate keys is used.
+func (e *JSONEvaluator) member(name string) ([]byte, error) {
+	if e.kind != EvaluatorKindObject {
+		return nil, nil
+	}
+
+	var result []byte
+	var decodeErr error
+	err := scanJSONObject(e.data, func(key []byte, value []byte) bool {
+		if bytes.IndexByte(key, '\\') >= 0 {
+			decoded, err := decodeJSONString(key)
+			if err != nil {
+				decodeErr = err
+				return false
+			}
+			if decoded != name {
+				return true
+			}
+		} else if string(key[1:len(key)-1]) != name {
+			return true
+		}
+
+		result = value
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil || (len(result) == 4 && string(result) == "null") {
+		return nil, err
+	}
+	return result, nil
+}
+
+// scanJSONObject calls member for every key and value of an object in order until it returns false.
+// Keys are passed with their quotes and escapes.
+func scanJSONObject(data []byte, member func(key []byte, value []byte) bool) error {
+	i := skipJSONSpace(data, 1)
+	if i < len(data) && data[i] == '}' {
+		return nil
+	}
+
+	for {
+		if i >= len(data) || data[i] != '"' {
+			return jsonSyntaxError(data, i, "object key")
+		}
+		keyEnd, err := skipJSONString(data, i)
+		if err != nil {
+			return err
+		}
+		key := data[i:keyEnd]
+
+		i = skipJSONSpace(data, keyEnd)
+		if i >= len(data) || data[i] != ':' {
+			return jsonSyntaxError(data, i, `":"`)
+		}
+		i = skipJSONSpace(data, i+1)
+		valueEnd, err := skipJSONValue(data, i)
+		if err != nil {
+			return err
+		}
+		if !member(key, data[i:valueEnd]) {
+			return nil
+		}
+
+		i = skipJSONSpace(data, valueEnd)
+		if i < len(data) && data[i] == '}' {
+			return nil
+		}
+		if i >= len(data) || data[i] != ',' {
+			return jsonSyntaxError(data, i, `"," or "}"`)
+		}
+		i = skipJSONSpace(data, i+1)
+	}
+}
+
+// scanJSONArray calls element for every element of an array in order until it returns false.
+func scanJSONArray(data []byte, element func(value []byte) bool) error {
+	i := skipJSONSpace(data, 1)
+	if i < len(data) && data[i] == ']' {
+		return nil
+	}
+
+	for {
+		end, err := skipJSONValue(data, i)
+		if err != nil {
+			return err
+		}
+		if !element(data[i:end]) {
+			return nil
+		}
+
+		i = skipJSONSpace(data, end)
+		if i < len(data) && data[i] == ']' {
+			return nil
+		}
+		if i >= len(data) || data[i] != ',' {
+			return jsonSyntaxError(data, i, `"," or "]"`)
+		}
+		i = skipJSONSpace(data, i+1)
+	}
+}
+
+func skipJSONSpace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// skipJSONString returns the index following the string starting at i.
+func skipJSONString(data []byte, i int) (int, error) {
+	for j := i + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1, nil
+		}
+	}
+	return 0, jsonSyntaxError(data, len(data), `end of string`)
+}
+
+// skipJSONValue returns the index following the value starting at i. Objects and arrays
+// are skipped by counting brackets outside of strings, their contents are checked
+// only when they are scanned.
+func skipJSONValue(data []byte, i int) (int, error) {
+	if i >= len(data) {
+		return 0, jsonSyntaxError(data, i, "value")
+	}
+
+	switch data[i] {
+	case '"':
+		return skipJSONString(data, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(data); j++ {
+			switch data[j] {
+			case '"':
+				end, err := skipJSONString(data, j)
+				if err != nil {
+					return 0, err
+				}
+				j = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1, nil
+				}
+			}
+		}
+		return 0, jsonSyntaxError(data, len(data), "end of object or array")
+	default:
+		j := i
+		for j < len(data) && !isJSONDelimiter(data[j]) {
+			j++
+		}
+		if j == i {
+			return 0, jsonSyntaxError(data, i, "value")
+		}
+		return j, nil
+	}
+}
+
+func isJSONDelimiter(c byte) bool {
+	switch c {
+	case ',', ':', '{', '}', '[', ']', '"', ' ', '\t', '\r', '\n':
+		return true
+	}
+	return false
+}
+
+func jsonSyntaxError(data []byte, offset int, expected string) error {
+	if offset >= len(data) {
+		return fmt.Errorf("invalid JSON: unexpected end of data, expected %s", expected)
+	}
+	return fmt.Errorf("invalid JSON at offset %d: unexpected %q, expected %s", offset, data[offset], expected)
+}
+
+// decodeJSONValue decodes a raw JSON value: scalars to Go values, arrays to []interface{}
+// and objects to map[string]interface{}.
+func decodeJSONValue(raw []byte) (interface{}, error) {
+	switch raw[0] {
+	case '"':
+		return decodeJSONString(raw)
+	case '{':
+		result := map[string]interface{}{}
+		var decodeErr error
+		err := scanJSONObject(raw, func(key []byte, value []byte) bool {
+			name, err := decodeJSONString(key)
+			if err == nil {
+				result[name], err = decodeJSONValue(value)
+			}
+			decodeErr = err
+			return err == nil
+		})
+		if err == nil {
+			err = decodeErr
+		}
+		return result, err
+	case '[':
+		result := []interface{}{}
+		var decodeErr error
+		err := scanJSONArray(raw, func(element []byte) bool {
+			var value interface{}
+			value, decodeErr = decodeJSONValue(element)
+			result = append(result, value)
+			return decodeErr == nil
+		})
+		if err == nil {
+			err = decodeErr
+		}
+		return result, err
+	}
+
+	switch string(raw) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return decodeJSONNumber(raw)
+}
+
+func decodeJSONString(raw []byte) (string, error) {
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return string(raw[1 : len(raw)-1]), nil
+	}
+
+	var result string
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return "", fmt.Errorf("invalid JSON string %s: %w", raw, err)
+	}
+	return result, nil
+}
+
+func decodeJSONNumber(raw []byte) (interface{}, error) {
+	if raw[0] != '-' && (raw[0] < '0' || raw[0] > '9') {
+		return nil, fmt.Errorf("invalid JSON value %q", raw)
+	}
+
+	f, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON value %q", raw)
+	}
+	return f, nil
+}
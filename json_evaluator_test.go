@@ -0,0 +1,211 @@
+package gokql
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const jsonDocument = `{
+	"@timestamp": "2024-03-05T10:20:30Z",
+	"level": "error",
+	"message": "connection refused: \"db\"",
+	"status": 503,
+	"duration": 12.5,
+	"bytes": 18446744073709551615,
+	"retried": true,
+	"parent": null,
+	"host": {"name": "web-1", "ip": "10.1.2.3", "tags": ["prod", "eu"]},
+	"events": [{"code": 1, "kind": "start"}, {"code": 2, "kind": "stop"}],
+	"path\/escaped": "x",
+	"empty": {},
+	"list": [1, 2.5, "three", [4]]
+}`
+
+func TestJSONEvaluatorValues(t *testing.T) {
+	evaluator, err := NewJSONEvaluator([]byte(jsonDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]interface{}{
+		"level":        "error",
+		"message":      `connection refused: "db"`,
+		"status":       503.0,
+		"duration":     12.5,
+		"bytes":        float64(18446744073709551615),
+		"retried":      true,
+		"parent":       nil,
+		"missing":      nil,
+		"path/escaped": "x",
+		"empty":        map[string]interface{}{},
+		"host":         map[string]interface{}{"name": "web-1", "ip": "10.1.2.3", "tags": []interface{}{"prod", "eu"}},
+		"list":         []interface{}{1.0, 2.5, "three", []interface{}{4.0}},
+	}
+	for name, expected := range values {
+		actual, err := evaluator.Evaluate(name)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", name, err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Unexpected value %#v of %s. Expected: %#v", actual, name, expected)
+		}
+	}
+
+	keys, err := evaluator.Keys()
+	expectedKeys := []string{"@timestamp", "level", "message", "status", "duration", "bytes", "retried", "parent",
+		"host", "events", "path/escaped", "empty", "list"}
+	if err != nil || !reflect.DeepEqual(keys, expectedKeys) {
+		t.Errorf("Unexpected keys %q, %v", keys, err)
+	}
+
+	events, err := evaluator.GetSubEvaluator("events")
+	if err != nil || events.GetEvaluatorKind() != EvaluatorKindSlice {
+		t.Fatalf("Unexpected events evaluator %v, %v", events, err)
+	}
+	items, err := events.GetArraySubEvaluators()
+	if err != nil || len(items) != 2 {
+		t.Fatalf("Unexpected array evaluators %v, %v", items, err)
+	}
+	if kind, _ := items[1].Evaluate("kind"); kind != "stop" {
+		t.Errorf("Unexpected kind of the second event: %v", kind)
+	}
+
+	if sub, err := evaluator.GetSubEvaluator("missing"); sub != nil || err != nil {
+		t.Errorf("Unexpected evaluator of a missing property: %v, %v", sub, err)
+	}
+	if _, err := evaluator.GetSubEvaluator("level"); err == nil {
+		t.Error("Expected error for a sub evaluator of a string")
+	}
+	list, _ := evaluator.GetSubEvaluator("list")
+	if _, err := list.GetArraySubEvaluators(); err == nil {
+		t.Error("Expected error for array sub evaluators of scalars")
+	}
+
+	duplicates, _ := NewJSONEvaluator([]byte(`{"a": 1, "a": 2}`))
+	if a, _ := duplicates.Evaluate("a"); a != 2.0 {
+		t.Errorf("Unexpected value of a duplicate key: %v", a)
+	}
+}
+
+func TestJSONEvaluatorErrors(t *testing.T) {
+	for _, document := range []string{"", "42", `"a"`, "null"} {
+		if _, err := NewJSONEvaluator([]byte(document)); err == nil {
+			t.Errorf("Expected error for document %q", document)
+		}
+	}
+
+	for _, document := range []string{`{"a" 1}`, `{"a": "x`, `{"a": [1, 2}`, `{a: 1}`, `{"b": 1, "a": tru}`, `{"a": {"b" 1}}`} {
+		evaluator, err := NewJSONEvaluator([]byte(document))
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := evaluator.Evaluate("a")
+		if err == nil {
+			t.Errorf("Expected error for document %s, got %v", document, value)
+		}
+	}
+
+	// a syntax error after a property is reported, as a later duplicate key would replace it
+	evaluator, _ := NewJSONEvaluator([]byte(`{"a": 1, "b": }`))
+	if a, err := evaluator.Evaluate("a"); err == nil {
+		t.Errorf("Expected error for a syntax error after the property, got %v", a)
+	}
+	// nested objects which are not evaluated are not checked
+	evaluator, _ = NewJSONEvaluator([]byte(`{"a": 1, "b": {"c" 2}}`))
+	if a, err := evaluator.Evaluate("a"); err != nil || a != 1.0 {
+		t.Errorf("Unexpected value %v, %v", a, err)
+	}
+}
+
+func TestJSONEvaluatorMatch(t *testing.T) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonDocument), &obj); err != nil {
+		t.Fatal(err)
+	}
+	mapEvaluator, _ := NewMapEvaluator(obj)
+	jsonEvaluator, err := NewJSONEvaluator([]byte(jsonDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := map[string]bool{
+		"level:error":                          true,
+		"level:(warn or err*)":                 true,
+		`message:"connection refused: \"db\""`: true,
+		"status>=500 and status<600":           true,
+		"status:503":                           true,
+		"duration>12":                          true,
+		"retried:true":                         true,
+		"parent:*":                             false,
+		"not missing:1":                        true,
+		"host.name:web-1 and host.tags:eu":     true,
+		"host:{name:web-1 and tags:prod}":      true,
+		"events:{code:2 and kind:stop}":        true,
+		"events:{code:1 and kind:stop}":        false,
+		"@timestamp:*":                         true,
+		"web-1":                                true,
+		"stop":                                 true,
+		"refused":                              false,
+		`path\/escaped:x`:                      true,
+		"host.tags:(prod and eu)":              true,
+		"empty:{a:1}":                          false,
+		"host.ip:10.0.0.0/8 or events:{code:(3 or 1)}": true,
+	}
+	for query, expected := range queries {
+		expr := mustParse(t, query)
+		mapResult, mapErr := expr.Match(mapEvaluator)
+		jsonResult, jsonErr := expr.Match(jsonEvaluator)
+		if jsonErr != nil || jsonResult != expected {
+			t.Errorf("Unexpected JSON evaluator result %v, %v for %s. Expected: %v", jsonResult, jsonErr, query, expected)
+		}
+		if mapErr != nil || mapResult != jsonResult {
+			t.Errorf("Map evaluator result %v, %v differs from JSON evaluator result %v for %s", mapResult, mapErr, jsonResult, query)
+		}
+	}
+
+	bytes, _ := ParseWithOptions("bytes>10000000000000000000", ParseOptions{})
+	if res, err := bytes.Match(jsonEvaluator); err != nil || !res {
+		t.Errorf("Unexpected match of a large unsigned integer: %v, %v", res, err)
+	}
+}
+
+func TestJSONEvaluatorAgreesWithUnmarshal(t *testing.T) {
+	document := []byte(`{"price": 10, "dup": 1, "nested": {"dup": "a", "dup": "b"}, "dup": 2, "items": [{"n": 1.5}, {"n": 2}]}`)
+	var obj map[string]interface{}
+	if err := json.Unmarshal(document, &obj); err != nil {
+		t.Fatal(err)
+	}
+	jsonEvaluator, err := NewJSONEvaluator(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluators := map[string]Evaluator{"map": mustMapEvaluator(t, obj), "json": jsonEvaluator}
+
+	for query, expected := range map[string]bool{
+		"price>'9.99'":              true,
+		"price<'10.5' and price:10": true,
+		"price:'10.0'":              true,
+		"dup:2 and not dup:1":       true,
+		"nested.dup:b":              true,
+		"nested:{dup:a}":            false,
+		"items:{n>'1.25' and n<2}":  true,
+		"items:{n:2}":               true,
+	} {
+		for name, evaluator := range evaluators {
+			res, err := mustParse(t, query).Match(evaluator)
+			if err != nil || res != expected {
+				t.Errorf("Unexpected result %v, %v of %s evaluator for %s. Expected: %v", res, err, name, query, expected)
+			}
+		}
+	}
+}
+
+func mustMapEvaluator(t *testing.T, obj map[string]interface{}) Evaluator {
+	t.Helper()
+	evaluator, err := NewMapEvaluator(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return evaluator
+}
@@ -0,0 +1,255 @@
+package gokql
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Limits bounds the complexity of queries accepted by ParseWithOptions, which protects
+// applications accepting queries from untrusted users from queries which are expensive to parse
+// or to match. A zero field means that the corresponding property of queries is not limited.
+type Limits struct {
+	// MaxLength is the maximum length of a query in bytes.
+	MaxLength int
+	// MaxDepth is the maximum nesting depth of parentheses and `{}` sub-expressions.
+	// Length and depth are checked before the query is parsed.
+	MaxDepth int
+	// MaxClauses is the maximum number of field matches and field-less terms.
+	MaxClauses int
+	// MaxValues is the maximum number of values in a single value list such as `status:(200 or 304)`.
+	MaxValues int
+	// MaxWildcards is the maximum number of `*` wildcards in values and field names of a query.
+	// The existence query `field:*` doesn't count.
+	MaxWildcards int
+	// MaxCost is the maximum cost of matching a query as estimated by Expression.Cost.
+	MaxCost int
+}
+
+// Limit identifies a property of queries bounded by Limits.
+type Limit int
+
+const (
+	LimitLength Limit = iota
+	LimitDepth
+	LimitClauses
+	LimitValues
+	LimitWildcards
+	LimitCost
+)
+
+func (l Limit) String() string {
+	switch l {
+	case LimitLength:
+		return "length"
+	case LimitDepth:
+		return "nesting depth"
+	case LimitClauses:
+		return "number of clauses"
+	case LimitValues:
+		return "value list size"
+	case LimitWildcards:
+		return "number of wildcards"
+	case LimitCost:
+		return "cost"
+	}
+	return fmt.Sprintf("Limit(%d)", int(l))
+}
+
+// LimitError is returned by ParseWithOptions when a query exceeds one of the ParseOptions.Limits.
+type LimitError struct {
+	// Limit is the exceeded limit.
+	Limit Limit
+	// Value is the measured value of the query and Max is the maximum allowed by the limits.
+	Value int
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("query %s %d exceeds the limit of %d", e.Limit, e.Value, e.Max)
+}
+
+// exceeds returns a *LimitError if the value is greater than a maximum which is not zero.
+func exceeds(limit Limit, value int, max int) error {
+	if max > 0 && value > max {
+		return &LimitError{Limit: limit, Value: value, Max: max}
+	}
+	return nil
+}
+
+// checkQueryLimits checks the limits which have to be enforced before a query is parsed,
+// as the parser needs stack proportional to the nesting of the query.
+func checkQueryLimits(query string, limits Limits) error {
+	if err := exceeds(LimitLength, len(query), limits.MaxLength); err != nil {
+		return err
+	}
+	if limits.MaxDepth == 0 {
+		return nil
+	}
+	return exceeds(LimitDepth, nestingDepth(query), limits.MaxDepth)
+}
+
+// nestingDepth returns the maximum nesting depth of parentheses and braces of a query.
+// Tokens which the parser would reject are counted as they are.
+func nestingDepth(query string) int {
+	lex, err := queryLexer.Lex(strings.NewReader(query))
+	if err != nil {
+		return 0
+	}
+
+	depth, maxDepth := 0, 0
+	for {
+		token, err := lex.Next()
+		if err != nil || token.EOF() {
+			return maxDepth
+		}
+
+		switch token.Value {
+		case "(", "{":
+			depth++
+			if depth > maxDepth {
+				maxDepth = depth
+			}
+		case ")", "}":
+			depth--
+		}
+	}
+}
+
+// checkExpressionLimits checks the limits of a parsed query.
+func checkExpressionLimits(expr *expression, options ParseOptions) error {
+	limits := options.Limits
+	var clauses, values, wildcards int
+	expr.visit(visitor{
+		atomicValue: func(atomic *atomicValue) {
+			if !atomic.quoted && atomic.regexp == nil && !atomic.isExistence() {
+				wildcards += atomic.wildcard.stars()
+			}
+		},
+		propertyMatch: func(prop *propertyMatch) {
+			clauses++
+			if prop.pattern != nil {
+				wildcards += prop.pattern.wildcard.stars()
+			}
+
+			count := 0
+			prop.visitValues(func(*atomicValue) {
+				count++
+			})
+			if count > values {
+				values = count
+			}
+		},
+		subExpression: func(se *subExpression) {
+			if se.FreeText != nil {
+				clauses++
+			}
+		},
+	})
+
+	if err := exceeds(LimitClauses, clauses, limits.MaxClauses); err != nil {
+		return err
+	}
+	if err := exceeds(LimitValues, values, limits.MaxValues); err != nil {
+		return err
+	}
+	if err := exceeds(LimitWildcards, wildcards, limits.MaxWildcards); err != nil {
+		return err
+	}
+	if limits.MaxCost == 0 {
+		return nil
+	}
+	return exceeds(LimitCost, expr.cost(splitFieldNames(options.DefaultFields)), limits.MaxCost)
+}
+
+// Relative costs of the parts of a query estimated by Expression.Cost.
+const (
+	// costValue is the cost of comparing a property with a value.
+	costValue = 1
+	// costStar is the additional cost of every star of a wildcard value.
+	costStar = 1
+	// costRegexp is the cost of matching a property with a regular expression.
+	costRegexp = 10
+	// costAllFields is the factor of field-less terms and field name patterns matched against all properties.
+	costAllFields = 10
+	// costNested is the factor of `{}` sub-expressions, which can be matched against every object of a slice.
+	costNested = 4
+	// maxCost caps estimated costs, so that they don't overflow for deeply nested queries.
+	maxCost = math.MaxInt32
+)
+
+// Cost estimates the relative cost of matching the expression against an item. Every comparison
+// of a property with a value costs 1, wildcards and regular expressions cost more, and terms and
+// field name patterns which are matched against all properties of an item cost a multiple of that.
+// The estimate doesn't depend on the items, so it can be used to reject expensive queries
+// before they are matched (see Limits.MaxCost).
+func (expression Expression) Cost() int {
+	if expression.ast == nil {
+		return 0
+	}
+	return expression.ast.cost(expression.defaultFields)
+}
+
+func (expr *expression) cost(defaultFields [][]string) int {
+	cost := 0
+	for _, conj := range append([]conjunction{expr.Expr.LeftValue}, expr.Expr.RightValues...) {
+		for _, se := range append([]subExpression{conj.LeftValue}, conj.RightValues...) {
+			cost = addCost(cost, se.cost(defaultFields))
+		}
+	}
+	return cost
+}
+
+func (se subExpression) cost(defaultFields [][]string) int {
+	switch {
+	case se.SubExpression != nil:
+		return se.SubExpression.cost(defaultFields)
+	case se.FreeText != nil:
+		fields := len(defaultFields)
+		if fields == 0 {
+			fields = costAllFields
+		}
+		return mulCost(se.FreeText.cost(), fields)
+	}
+	return se.Value.cost(defaultFields)
+}
+
+func (prop *propertyMatch) cost(defaultFields [][]string) int {
+	cost := 0
+	if prop.ValueSubExpression != nil {
+		cost = mulCost(prop.ValueSubExpression.cost(defaultFields), costNested)
+	} else {
+		prop.visitValues(func(atomic *atomicValue) {
+			cost = addCost(cost, atomic.cost())
+		})
+	}
+
+	if prop.pattern != nil {
+		return mulCost(cost, costAllFields)
+	}
+	return cost
+}
+
+func (atomic *atomicValue) cost() int {
+	switch {
+	case atomic.regexp != nil:
+		return costRegexp
+	case atomic.quoted || atomic.isExistence():
+		return costValue
+	}
+	return costValue + costStar*atomic.wildcard.stars()
+}
+
+func addCost(a int, b int) int {
+	if a > maxCost-b {
+		return maxCost
+	}
+	return a + b
+}
+
+func mulCost(a int, b int) int {
+	if b != 0 && a > maxCost/b {
+		return maxCost
+	}
+	return a * b
+}
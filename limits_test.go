@@ -0,0 +1,110 @@
+package gokql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	testLimit := func(query string, limits Limits, limit Limit, value int) {
+		t.Helper()
+		_, err := ParseWithOptions(query, ParseOptions{Limits: limits})
+		var limitError *LimitError
+		if !errors.As(err, &limitError) {
+			t.Fatalf("Expected *LimitError for %q, got %T: %v", query, err, err)
+		}
+		if limitError.Limit != limit || limitError.Value != value {
+			t.Errorf("Unexpected limit error %v for %q. Expected %v %d", limitError, query, limit, value)
+		}
+	}
+	testAccepted := func(query string, limits Limits) {
+		t.Helper()
+		if _, err := ParseWithOptions(query, ParseOptions{Limits: limits}); err != nil {
+			t.Errorf("Unexpected error for %q: %v", query, err)
+		}
+	}
+
+	testLimit("a:12345", Limits{MaxLength: 6}, LimitLength, 7)
+	testAccepted("a:12345", Limits{MaxLength: 7})
+
+	testLimit("((a:1 or b:2) and c:{d:(1 or 2)})", Limits{MaxDepth: 2}, LimitDepth, 3)
+	testAccepted("((a:1 or b:2) and c:{d:(1 or 2)})", Limits{MaxDepth: 3})
+	testAccepted(`a:"((((" and b:\(\(\(`, Limits{MaxDepth: 1})
+	testLimit(strings.Repeat("(", 100000), Limits{MaxDepth: 100}, LimitDepth, 100000)
+
+	testLimit("a:1 and (b:2 or error) and c:{d:1 and e:2}", Limits{MaxClauses: 5}, LimitClauses, 6)
+	testAccepted("a:1 and (b:2 or error) and c:{d:1 and e:2}", Limits{MaxClauses: 6})
+
+	testLimit("a:(1 or 2 or (3 and not 4)) and b:(1 or 2)", Limits{MaxValues: 3}, LimitValues, 4)
+	testAccepted("a:(1 or 2 or (3 and not 4)) and b:(1 or 2)", Limits{MaxValues: 4})
+
+	testLimit(`a:*b*c and d*:x* and *e and f:*`, Limits{MaxWildcards: 4}, LimitWildcards, 5)
+	testAccepted(`a:*b*c and d*:x* and *e and f:* and g:"*" and h:\* and i:/.*/`, Limits{MaxWildcards: 5})
+
+	testLimit("a:1 and b:/x/ and error", Limits{MaxCost: 20}, LimitCost, 21)
+	testAccepted("a:1 and b:/x/ and error", Limits{MaxCost: 21})
+
+	var limitError *LimitError
+	_, err := ParseWithOptions("a:1 or b:2", ParseOptions{Limits: Limits{MaxClauses: 1}})
+	if !errors.As(err, &limitError) || err.Error() != "query number of clauses 2 exceeds the limit of 1" {
+		t.Errorf("Unexpected error message %v", err)
+	}
+}
+
+func TestLimitsOfNodes(t *testing.T) {
+	options := ParseOptions{Limits: Limits{MaxClauses: 2, MaxWildcards: 1}}
+	expr, err := ParseWithOptions("a:1 and b:x*", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := &AndNode{Children: []Node{expr.Root(), &TermNode{Value: &ValueNode{Text: "error"}}}}
+	var limitError *LimitError
+	if _, err := NewExpression(node, options); !errors.As(err, &limitError) || limitError.Limit != LimitClauses {
+		t.Errorf("Expected clauses limit error of a built expression, got %v", err)
+	}
+
+	_, err = Rewrite(expr, func(node Node) (Node, error) {
+		if match, ok := node.(*MatchNode); ok && match.Field[0] == "a" {
+			return &MatchNode{Field: []string{"a*"}, FieldPattern: true, Operator: ":", Value: match.Value}, nil
+		}
+		return node, nil
+	})
+	if !errors.As(err, &limitError) || limitError.Limit != LimitWildcards || limitError.Value != 2 {
+		t.Errorf("Expected wildcards limit error of a rewritten expression, got %v", err)
+	}
+}
+
+func TestCost(t *testing.T) {
+	for query, expected := range map[string]int{
+		"a:1":                     1,
+		"a:1 and b:2 or c:3":      3,
+		"a:(1 or 2 or 3)":         3,
+		"a:* and b:'x*'":          2,
+		"a:x* and b:*x*":          5,
+		"a:/x+/":                  10,
+		"error":                   10,
+		"a.*:1":                   10,
+		"a:{b:1 and c:2}":         8,
+		"a:{b:{c:1}}":             16,
+		"not (a:1 or b:(2 or 3))": 3,
+	} {
+		if cost := mustParse(t, query).Cost(); cost != expected {
+			t.Errorf("Unexpected cost %d of %s. Expected: %d", cost, query, expected)
+		}
+	}
+
+	expr := mustParse(t, "error and a:1")
+	if cost := expr.WithDefaultFields("message", "host").Cost(); cost != 3 {
+		t.Errorf("Unexpected cost %d with default fields", cost)
+	}
+	if cost := (Expression{}).Cost(); cost != 0 {
+		t.Errorf("Unexpected cost %d of empty expression", cost)
+	}
+
+	deep := strings.Repeat("a:{", 40) + "b:1" + strings.Repeat("}", 40)
+	if cost := mustParse(t, deep).Cost(); cost != maxCost {
+		t.Errorf("Unexpected cost %d of deeply nested expression", cost)
+	}
+}
@@ -0,0 +1,124 @@
+package gokql
+
+import (
+	"strings"
+	"time"
+)
+
+// ParseOptions controls how a query is parsed and how the resulting expression is matched.
+// The zero value gives the default behavior of Parse.
+type ParseOptions struct {
+	// DefaultFields lists dotted property names which field-less terms such as `error`
+	// or `"connection refused"` are matched against. If it is empty, such terms are
+	// matched against every property the evaluator can enumerate (see KeysEvaluator).
+	DefaultFields []string
+	// RangeQuantifier defines how range operations (<, <=, >, >=) match slice properties.
+	// Equality on a slice property always matches if any element is equal to the value.
+	RangeQuantifier ArrayQuantifier
+	// TypeHandlers compare properties of custom types with query values. Properties of named
+	// types with a built-in underlying kind, such as `type Status string`, are compared
+	// as their underlying kind unless a handler is registered for them.
+	TypeHandlers TypeHandlers
+	// Schema declares how values of properties are interpreted, for example that strings
+	// of a property hold IP addresses. Properties are keyed by their dotted names,
+	// with names of nested sub-expressions included: `a:{b:1}` refers to "a.b".
+	// It also applies to the properties field-less terms and field name patterns are matched
+	// against, so `10.0.0.0/8` matches a default field "ip" declared as FieldIP.
+	Schema Schema
+	// Clock returns the current time which date math such as `now-15m` or `now/d` is relative to.
+	// If it is nil, time.Now is used. The time is read on every comparison, so a parsed expression
+	// keeps matching against the current time.
+	Clock func() time.Time
+	// StringMatching selects how string properties are compared with values, for example
+	// case-insensitively. It applies to equality, wildcards and range operations and can be
+	// overridden per property in the Schema.
+	StringMatching StringMatching
+	// Missing defines which property values are treated like missing properties.
+	Missing MissingPolicy
+	// DisableRegexps rejects regular expression values such as `path:/\/api\/.*/` with a parse error.
+	// Regular expressions run in linear time, but they can still be costly to compile and match,
+	// so it is recommended to disable them for queries from untrusted users.
+	DisableRegexps bool
+	// Limits bounds the complexity of parsed queries. Queries exceeding them are rejected with a *LimitError.
+	Limits Limits
+}
+
+// Schema declares how values of properties are interpreted, keyed by dotted property name.
+type Schema map[string]FieldSchema
+
+// FieldSchema declares how values of a property are interpreted.
+type FieldSchema struct {
+	// Type declares the type of string values of the property.
+	Type FieldType
+	// StringMatching overrides ParseOptions.StringMatching for the property if it is not zero.
+	// Use ExactStrings to compare the property byte by byte regardless of the expression options.
+	StringMatching StringMatching
+	// Analyzer splits values of a FieldText property into tokens. If it is nil, StandardAnalyzer is used.
+	Analyzer Analyzer
+}
+
+// FieldType declares the type of string property values.
+type FieldType int
+
+const (
+	// FieldDefault compares property values according to their Go type.
+	FieldDefault FieldType = iota
+	// FieldIP compares string values as IP addresses, like net.IP and netip.Addr values:
+	// `ip:10.0.0.0/8` matches addresses of the network and range operations compare addresses.
+	FieldIP
+	// FieldKeyword compares string values as whole strings, which is the default for strings.
+	FieldKeyword
+	// FieldText compares string values as full text split into tokens by the Analyzer of the field.
+	// A quoted value such as `message:"connection refused"` matches the tokens as a phrase and
+	// an unquoted value such as `message:refused` matches any of its tokens.
+	FieldText
+)
+
+// ArrayQuantifier defines how many elements of a slice property have to satisfy a comparison.
+type ArrayQuantifier int
+
+const (
+	// AnyElement matches if at least one element satisfies the comparison, as Elasticsearch does.
+	AnyElement ArrayQuantifier = iota
+	// AllElements matches if the slice is not empty and every element satisfies the comparison.
+	AllElements
+)
+
+// StringMatching selects how string values are compared. Modes can be combined with `|`.
+// The zero value compares strings byte by byte.
+type StringMatching uint8
+
+const (
+	// FoldCase compares strings case-insensitively with the semantics of strings.EqualFold.
+	FoldCase StringMatching = 1 << iota
+	// NormalizeUnicode compares strings in Unicode normalization form NFC,
+	// so composed and decomposed forms of the same characters are equal.
+	NormalizeUnicode
+	// ExactStrings compares strings byte by byte. It is used in a FieldSchema to opt a property
+	// out of the string matching of the expression.
+	ExactStrings
+)
+
+// MissingPolicy defines which property values are treated like missing properties. A missing
+// property doesn't match any value, so the existence query `field:*` doesn't match it
+// and `not field:*` does. Nil values, such as nil pointers, slices, maps and JSON nulls,
+// are always missing; the zero value treats all other values as present.
+type MissingPolicy struct {
+	// EmptyStrings treats empty strings as missing.
+	EmptyStrings bool
+	// EmptyCollections treats slices, arrays and maps without present elements as missing,
+	// as Elasticsearch does for empty arrays.
+	EmptyCollections bool
+}
+
+func splitFieldNames(fields []string) [][]string {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	result := make([][]string, len(fields))
+	for i, field := range fields {
+		result[i] = strings.Split(field, ".")
+	}
+	return result
+}
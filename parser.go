@@ -1,37 +1,61 @@
 package gokql
 
 import (
-	"reflect"
+	"errors"
+	"regexp"
 	"strings"
 
 	"github.com/alecthomas/participle"
+	"github.com/alecthomas/participle/lexer"
 	"github.com/alecthomas/participle/lexer/stateful"
 )
 
 type Expression struct {
-	ast *expression
+	ast           *expression
+	options       ParseOptions
+	defaultFields [][]string
 }
 
 type atomicValue struct {
-	Value     string `@Literal | @QuotedString | @DquotedString`
+	Pos       lexer.Position
+	Value     string `@IPAddress | @DateMath | @Number | @Literal | @QuotedString | @DquotedString | @Regexp`
+	quoted    bool
 	wildcard  wildcard
-	valueType reflect.Type
-	comparer  func(propertyValue interface{}) bool
+	regexp    *regexp.Regexp
+	comparers *comparerCache
 }
 
 type propertyMatch struct {
-	Name               []string      `@Literal ('.' @Literal)*`
-	Operation          string        `@(':' | '<' | '>' | '<=' | '>=')`
-	ValueSubExpression *expression   `( ('{' @@ '}')`
-	AtomicValue        *atomicValue  `| @@`
-	OrValues           []atomicValue `| ('(' @@ ('or' @@)+')')`
-	AndValues          []atomicValue `| ('(' @@ ('and' @@)+')'))`
+	Name               []string          `@Literal ('.' @Literal)*`
+	Operation          string            `@(':' | '<' | '>' | '<=' | '>=')`
+	ValueSubExpression *expression       `( ('{' @@ '}')`
+	AtomicValue        *atomicValue      `| @@`
+	ValueList          *valueDisjunction `| ('(' @@ ')'))`
+	// pattern is set if the name contains wildcards. Name then holds the names in pattern form.
+	pattern *fieldPattern
+}
+
+type valueTerm struct {
+	IsInverted bool              `@"not"?`
+	ValueList  *valueDisjunction `('(' @@ ')'`
+	Value      *atomicValue      `| @@)`
+}
+
+type valueConjunction struct {
+	LeftValue   valueTerm   `@@`
+	RightValues []valueTerm `('and' @@)*`
+}
+
+type valueDisjunction struct {
+	LeftValue   valueConjunction   `@@`
+	RightValues []valueConjunction `('or' @@)*`
 }
 
 type subExpression struct {
 	IsInverted    bool           `@"not"?`
 	SubExpression *expression    `('(' @@ ')'`
-	Value         *propertyMatch `| @@)`
+	Value         *propertyMatch `| @@`
+	FreeText      *atomicValue   `| @@)`
 }
 
 type conjunction struct {
@@ -49,10 +73,14 @@ type expression struct {
 }
 
 var (
-	lexer, _ = stateful.NewSimple([]stateful.Rule{
-		{"QuotedString", `'[^']*'`, nil},
-		{"DquotedString", `"[^"]*"`, nil},
-		{"Literal", `[a-zA-Z0-9*\\-_]+`, nil},
+	queryLexer, _ = stateful.NewSimple([]stateful.Rule{
+		{"QuotedString", `'(\\.|[^'\\])*'`, nil},
+		{"DquotedString", `"(\\.|[^"\\])*"`, nil},
+		{"Regexp", `/(\\.|[^/\\])*/`, nil},
+		{"IPAddress", ipAddressPattern, nil},
+		{"DateMath", dateMathPattern, nil},
+		{"Number", numberPattern, nil},
+		{"Literal", literalPattern, nil},
 		{"<=", `<=`, nil},
 		{">=", `>=`, nil},
 		{"whitespace", `[ \t\r\n]+`, nil},
@@ -61,34 +89,175 @@ var (
 
 	parser = participle.MustBuild(
 		&expression{},
-		participle.Lexer(lexer),
+		participle.Lexer(queryLexer),
 		participle.UseLookahead(10))
 )
 
-func parse(query string) (*expression, error) {
+func parse(query string, options ParseOptions) (*expression, error) {
+	if err := checkQueryLimits(query, options.Limits); err != nil {
+		return nil, err
+	}
+
 	var expr expression
 	err := parser.ParseString(query, &expr)
 	if err != nil {
-		return nil, err
+		return nil, newParseError(query, err)
 	}
 
-	visitor := visitor{}
-	visitor.atomicValue = func(atomic *atomicValue) {
-		atomic.Value = unquote(atomic.Value)
-		atomic.wildcard = newWildcard(atomic.Value)
+	var invalid *ParseError
+	expr.visit(visitor{
+		atomicValue: func(atomic *atomicValue) {
+			switch {
+			case isQuoted(atomic.Value):
+				atomic.setValue(unescapeQuoted(atomic.Value), true)
+			case isRegexp(atomic.Value):
+				if err := atomic.setRegexp(atomic.Value[1 : len(atomic.Value)-1]); err != nil && invalid == nil {
+					invalid = newValueError(query, atomic.Pos.Offset, atomic.Value, err)
+				}
+			default:
+				atomic.setValue(unescapeLiteral(atomic.Value), false)
+			}
+		},
+		propertyMatch: func(prop *propertyMatch) {
+			for i, name := range prop.Name {
+				prop.Name[i] = unescapeLiteral(name)
+			}
+			prop.setName(prop.Name)
+		},
+	})
+	if invalid != nil {
+		return nil, invalid
 	}
-	expr.visit(visitor)
+	if atomic, err := checkRegexps(&expr, options); err != nil {
+		return nil, newValueError(query, atomic.Pos.Offset, atomic.String(), err)
+	}
+	if err := checkExpressionLimits(&expr, options); err != nil {
+		return nil, err
+	}
+	prepare(&expr, options)
 
 	return &expr, err
 }
 
+// checkRegexps returns the first regular expression which is disabled by the options
+// or compared with a range operation, along with the error describing the problem.
+func checkRegexps(expr *expression, options ParseOptions) (*atomicValue, error) {
+	var invalid *atomicValue
+	var invalidErr error
+	report := func(atomic *atomicValue, err error) {
+		if invalidErr == nil || atomic.Pos.Offset < invalid.Pos.Offset {
+			invalid, invalidErr = atomic, err
+		}
+	}
+
+	expr.visit(visitor{
+		atomicValue: func(atomic *atomicValue) {
+			if atomic.regexp != nil && options.DisableRegexps {
+				report(atomic, errors.New("regular expressions are disabled"))
+			}
+		},
+		propertyMatch: func(prop *propertyMatch) {
+			if prop.Operation == ":" {
+				return
+			}
+			prop.visitValues(func(atomic *atomicValue) {
+				if atomic.regexp != nil {
+					report(atomic, errors.New(`regular expressions can only be matched with ":"`))
+				}
+			})
+		},
+	})
+	return invalid, invalidErr
+}
+
+// prepare initializes the parts of the AST which are not produced by the grammar.
+func prepare(expr *expression, options ParseOptions) {
+	expr.visit(visitor{
+		atomicValue: func(atomic *atomicValue) {
+			atomic.comparers = &comparerCache{
+				handlers: options.TypeHandlers,
+				clock:    options.Clock,
+				strings:  options.StringMatching,
+			}
+		},
+	})
+
+	if len(options.Schema) > 0 {
+		expr.Expr.applySchema(options.Schema, nil)
+	}
+}
+
+// applySchema binds the schema of every property to the values it is compared with.
+// Path holds the names of the nested sub-expressions containing the disjunction.
+func (d *disjunction) applySchema(schema Schema, path []string) {
+	for _, conj := range append([]conjunction{d.LeftValue}, d.RightValues...) {
+		for _, se := range append([]subExpression{conj.LeftValue}, conj.RightValues...) {
+			if se.SubExpression != nil {
+				se.SubExpression.Expr.applySchema(schema, path)
+				continue
+			}
+			if se.Value == nil {
+				continue
+			}
+
+			fieldPath := append(append([]string{}, path...), se.Value.Name...)
+			if se.Value.ValueSubExpression != nil {
+				se.Value.ValueSubExpression.Expr.applySchema(schema, fieldPath)
+				continue
+			}
+
+			field, ok := schema[strings.Join(fieldPath, ".")]
+			if !ok {
+				continue
+			}
+			se.Value.visit(visitor{
+				atomicValue: func(atomic *atomicValue) {
+					atomic.comparers.field = field
+				},
+			})
+		}
+	}
+}
+
+// Parse parses a KQL query using default options.
+// Syntax errors are reported as *ParseError.
 func Parse(query string) (Expression, error) {
-	ast, err := parse(query)
+	return ParseWithOptions(query, ParseOptions{})
+}
+
+// ParseWithOptions parses a KQL query and binds the given options to the resulting expression.
+// Queries exceeding options.Limits are reported as *LimitError.
+func ParseWithOptions(query string, options ParseOptions) (Expression, error) {
+	ast, err := parse(query, options)
 	if err != nil {
 		return Expression{}, err
 	}
 
-	return Expression{ast}, nil
+	return newExpression(ast, options), nil
+}
+
+func newExpression(ast *expression, options ParseOptions) Expression {
+	return Expression{
+		ast:           ast,
+		options:       options,
+		defaultFields: splitFieldNames(options.DefaultFields),
+	}
+}
+
+// WithDefaultFields returns a copy of the expression that matches field-less terms
+// against the given dotted field names instead of the ones set at parse time.
+func (expression Expression) WithDefaultFields(fields ...string) Expression {
+	options := expression.options
+	options.DefaultFields = fields
+	return newExpression(expression.ast, options)
+}
+
+// String returns the normalized textual representation of the expression.
+func (expression Expression) String() string {
+	if expression.ast == nil {
+		return ""
+	}
+	return expression.ast.String()
 }
 
 type visitor struct {
@@ -100,8 +269,79 @@ type visitor struct {
 	expression    func(*expression)
 }
 
+// setValue sets the value from the literal text of a quoted value
+// or from the pattern form of an unquoted one, which can contain wildcards.
+func (atomic *atomicValue) setValue(text string, quoted bool) {
+	atomic.quoted = quoted
+	if quoted {
+		atomic.Value = text
+		atomic.wildcard = literalWildcard(text)
+	} else {
+		atomic.Value = unescapePattern(text)
+		atomic.wildcard = newWildcard(text)
+	}
+}
+
+// setRegexp sets the value to a regular expression in RE2 syntax. Like in Lucene,
+// the expression has to match the whole string rather than a part of it.
+func (atomic *atomicValue) setRegexp(pattern string) error {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return err
+	}
+	anchored, err := regexp.Compile(anchorRegexp(pattern))
+	if err != nil {
+		return err
+	}
+
+	atomic.Value = pattern
+	atomic.quoted = false
+	atomic.wildcard = literalWildcard(pattern)
+	atomic.regexp = anchored
+	return nil
+}
+
+// text returns the literal text of a quoted value, the pattern of a regular expression
+// or the pattern form of an unquoted value.
+func (atomic *atomicValue) text() string {
+	if atomic.quoted || atomic.regexp != nil {
+		return atomic.Value
+	}
+	return atomic.wildcard.format("*", escapePattern)
+}
+
+// setName sets the name of the property from names in pattern form.
+func (prop *propertyMatch) setName(names []string) {
+	prop.pattern = newFieldPattern(names)
+	prop.Name = names
+	if prop.pattern == nil {
+		for i, name := range names {
+			prop.Name[i] = unescapePattern(name)
+		}
+	}
+}
+
+// fieldName formats the name of the property so that it is parsed back to the same name.
+func (prop *propertyMatch) fieldName() string {
+	if prop.pattern != nil {
+		return formatFieldPattern(prop.Name)
+	}
+	return formatFieldName(prop.Name)
+}
+
+// isExistence reports whether the value is an unquoted star, which matches any present property
+// when it is compared for equality.
+func (atomic *atomicValue) isExistence() bool {
+	return !atomic.quoted && atomic.wildcard.matchesAll()
+}
+
 func (atomic atomicValue) String() string {
-	return atomic.Value
+	if atomic.quoted {
+		return quoteValue(atomic.Value)
+	}
+	if atomic.regexp != nil {
+		return formatRegexp(atomic.Value)
+	}
+	return formatPattern(atomic.text())
 }
 
 func (prop propertyMatch) String() string {
@@ -110,29 +350,52 @@ func (prop propertyMatch) String() string {
 		valueStr = "{" + prop.ValueSubExpression.String() + "}"
 	} else if prop.AtomicValue != nil {
 		valueStr = prop.AtomicValue.String()
-	} else if prop.OrValues != nil {
-		valueStr += "("
-		for i, orValue := range prop.OrValues {
-			if i == 0 {
-				valueStr += orValue.String()
-			} else {
-				valueStr += " or " + orValue.String()
-			}
+	} else if prop.ValueList != nil {
+		valueStr = prop.ValueList.String()
+		if !strings.HasPrefix(valueStr, "(") {
+			valueStr = "(" + valueStr + ")"
 		}
-		valueStr += ")"
-	} else if prop.AndValues != nil {
-		valueStr += "("
-		for i, orValue := range prop.OrValues {
-			if i == 0 {
-				valueStr += orValue.String()
-			} else {
-				valueStr += " and " + orValue.String()
-			}
+	}
+
+	return prop.fieldName() + prop.Operation + valueStr
+}
+
+func (v valueTerm) String() string {
+	notPrefix := ""
+	if v.IsInverted {
+		notPrefix = "not "
+	}
+
+	if v.ValueList != nil {
+		return notPrefix + v.ValueList.String()
+	}
+	return notPrefix + v.Value.String()
+}
+
+func (c valueConjunction) String() string {
+	result := c.LeftValue.String()
+	if c.RightValues != nil {
+		for _, v := range c.RightValues {
+			result += " and " + v.String()
+		}
+
+		result = "(" + result + ")"
+	}
+
+	return result
+}
+
+func (d valueDisjunction) String() string {
+	result := d.LeftValue.String()
+	if d.RightValues != nil {
+		for _, v := range d.RightValues {
+			result += " or " + v.String()
 		}
-		valueStr += ")"
+
+		result = "(" + result + ")"
 	}
 
-	return strings.Join(prop.Name, ".") + prop.Operation + valueStr
+	return result
 }
 
 func (expr subExpression) String() string {
@@ -143,6 +406,8 @@ func (expr subExpression) String() string {
 
 	if expr.SubExpression != nil {
 		return notPrefix + expr.SubExpression.String()
+	} else if expr.FreeText != nil {
+		return notPrefix + expr.FreeText.String()
 	} else {
 		return notPrefix + expr.Value.String()
 	}
@@ -178,20 +443,12 @@ func (expr expression) String() string {
 	return expr.Expr.String()
 }
 
-func unquote(str string) string {
-	if len(str) == 0 {
-		return str
-	}
-
-	if str[0] == '"' {
-		return strings.Trim(str, "\"")
-	}
-
-	if str[0] == '\'' {
-		return strings.Trim(str, "'")
-	}
+func isQuoted(str string) bool {
+	return len(str) > 0 && (str[0] == '"' || str[0] == '\'')
+}
 
-	return str
+func isRegexp(str string) bool {
+	return len(str) > 1 && str[0] == '/'
 }
 
 func (expr *expression) visit(visitor visitor) {
@@ -230,6 +487,9 @@ func (e *subExpression) visit(visitor visitor) {
 	if e.Value != nil {
 		e.Value.visit(visitor)
 	}
+	if e.FreeText != nil {
+		e.FreeText.visit(visitor)
+	}
 
 	if visitor.subExpression != nil {
 		visitor.subExpression(e)
@@ -243,15 +503,8 @@ func (pm *propertyMatch) visit(visitor visitor) {
 	if pm.ValueSubExpression != nil {
 		pm.ValueSubExpression.visit(visitor)
 	}
-	if pm.OrValues != nil {
-		for i := range pm.OrValues {
-			pm.OrValues[i].visit(visitor)
-		}
-	}
-	if pm.AndValues != nil {
-		for i := range pm.AndValues {
-			pm.AndValues[i].visit(visitor)
-		}
+	if pm.ValueList != nil {
+		pm.ValueList.visit(visitor)
 	}
 
 	if visitor.propertyMatch != nil {
@@ -259,6 +512,40 @@ func (pm *propertyMatch) visit(visitor visitor) {
 	}
 }
 
+// visitValues calls visit for the atomic value or every value of the value list of the property match.
+func (pm *propertyMatch) visitValues(visit func(*atomicValue)) {
+	values := visitor{atomicValue: visit}
+	if pm.AtomicValue != nil {
+		pm.AtomicValue.visit(values)
+	}
+	if pm.ValueList != nil {
+		pm.ValueList.visit(values)
+	}
+}
+
+func (d *valueDisjunction) visit(visitor visitor) {
+	d.LeftValue.visit(visitor)
+	for i := range d.RightValues {
+		d.RightValues[i].visit(visitor)
+	}
+}
+
+func (c *valueConjunction) visit(visitor visitor) {
+	c.LeftValue.visit(visitor)
+	for i := range c.RightValues {
+		c.RightValues[i].visit(visitor)
+	}
+}
+
+func (v *valueTerm) visit(visitor visitor) {
+	if v.ValueList != nil {
+		v.ValueList.visit(visitor)
+	}
+	if v.Value != nil {
+		v.Value.visit(visitor)
+	}
+}
+
 func (atomic *atomicValue) visit(visitor visitor) {
 	if visitor.atomicValue != nil {
 		visitor.atomicValue(atomic)
@@ -4,7 +4,7 @@ import "testing"
 
 func TestParse(t *testing.T) {
 	testExpr := func(expression string, expectedExpr string) {
-		expr, err := parse(expression)
+		expr, err := parse(expression, ParseOptions{})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -14,14 +14,26 @@ func TestParse(t *testing.T) {
 		}
 	}
 
-	testExpr("a.b.c.d:'1'", "a.b.c.d:1")
-	testExpr("a:'1'", "a:1")
-	testExpr("a_b:'1'", "a_b:1")
+	testExpr("a.b.c.d:'1'", `a.b.c.d:"1"`)
+	testExpr("a:'1'", `a:"1"`)
+	testExpr("a_b:'1'", `a_b:"1"`)
 	testExpr("a:c or b:2", "(a:c or b:2)")
 	testExpr("a:c or b:2 and c:3", "(a:c or (b:2 and c:3))")
 	testExpr("(a:c or b:2) and c:3", "((a:c or b:2) and c:3)")
 	testExpr(
 		"a.b:c or b:2 and (c<=3 or d:{da:a or db:'b'}) or list:(1 or 2 or 3)",
-		"(a.b:c or (b:2 and (c<=3 or d:{(da:a or db:b)})) or list:(1 or 2 or 3))")
+		`(a.b:c or (b:2 and (c<=3 or d:{(da:a or db:"b")})) or list:(1 or 2 or 3))`)
 	testExpr("a>0 or b<1 or c>=1 or d<=1", "(a>0 or b<1 or c>=1 or d<=1)")
+	testExpr("error", "error")
+	testExpr("'connection refused'", `"connection refused"`)
+	testExpr("error and not level:info", "(error and not level:info)")
+	testExpr("(error or warn*) and a:1", "((error or warn*) and a:1)")
+	testExpr("status:(200 or (3* and not 304))", "status:(200 or (3* and not 304))")
+	testExpr("status:(not 304)", "status:(not 304)")
+	testExpr("status:((200))", "status:(200)")
+	testExpr("a>=(1 or 2)", "a>=(1 or 2)")
+	testExpr("@timestamp>=now-15m and created<now/d", "(@timestamp>=now-15m and created<now/d)")
+	testExpr("host:web-1 and day:2024-01-01 and delta>-5", "(host:web-1 and day:2024-01-01 and delta>-5)")
+	testExpr("nowhere or now or now-1d/d", "(nowhere or now or now-1d/d)")
+	testExpr("source.ip:10.0.0.0/8 or ip:(1.2.3.4 or 5.6.7.8)", "(source.ip:10.0.0.0/8 or ip:(1.2.3.4 or 5.6.7.8))")
 }
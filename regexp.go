@@ -0,0 +1,81 @@
+package gokql
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+)
+
+// ================ REGEXP  ====================
+
+// regexpTypeHandler matches string properties with a regular expression value such as `/ab+c/`.
+// The expression has to match the whole string, or any token of a text field (see FieldText).
+// Regular expressions only support equality, range operations with them are rejected by the parser.
+type regexpTypeHandler struct {
+	regexp   *regexp.Regexp
+	pattern  string
+	matching StringMatching
+	analyzer Analyzer
+}
+
+func newRegexpTypeHandler(atomic *atomicValue) regexpTypeHandler {
+	handler := regexpTypeHandler{
+		regexp:   atomic.regexp,
+		pattern:  atomic.Value,
+		matching: atomic.comparers.stringMatching(),
+	}
+	if field := atomic.comparers.fieldSchema(); field.Type == FieldText {
+		handler.analyzer = field.Analyzer
+		if handler.analyzer == nil {
+			handler.analyzer = StandardAnalyzer
+		}
+	}
+	return handler
+}
+
+// convert returns the compiled regular expression, made case-insensitive if strings are matched with FoldCase.
+func (h regexpTypeHandler) convert(value string) (result interface{}, err error) {
+	if h.matching&FoldCase != 0 {
+		return regexp.Compile(`(?i)` + anchorRegexp(value))
+	}
+	return h.regexp, nil
+}
+
+func (h regexpTypeHandler) equal(left interface{}, right interface{}) bool {
+	re := right.(*regexp.Regexp)
+	value := h.matching.apply(left.(string))
+	if h.analyzer == nil {
+		return re.MatchString(value)
+	}
+
+	for _, token := range h.analyzer.Analyze(value) {
+		if re.MatchString(token) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h regexpTypeHandler) greater(left interface{}, right interface{}) bool {
+	return false
+}
+
+func (h regexpTypeHandler) less(left interface{}, right interface{}) bool {
+	return false
+}
+
+func (h regexpTypeHandler) greaterOrEqual(left interface{}, right interface{}) bool {
+	return false
+}
+
+func (h regexpTypeHandler) lessOrEqual(left interface{}, right interface{}) bool {
+	return false
+}
+
+// createRegexpComparer creates the comparer of a regular expression value, which can only be matched with strings.
+func createRegexpComparer(propertyValue interface{}, atomic *atomicValue, comparer comparer) (*typedComparer, error) {
+	if _, ok := propertyValue.(string); !ok {
+		return nil, fmt.Errorf("regular expression %s cannot be matched with property of type %s", atomic, reflect.TypeOf(propertyValue))
+	}
+	return createComparerForHandler(newRegexpTypeHandler(atomic), propertyValue, atomic, comparer)
+}
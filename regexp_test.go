@@ -0,0 +1,206 @@
+package gokql
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"regexp/syntax"
+	"testing"
+)
+
+func TestRegexp(t *testing.T) {
+	obj := map[string]interface{}{
+		"path":    "/api/v2/users",
+		"agent":   "Mozilla/5.0 (X11; Linux x86_64)",
+		"tags":    []interface{}{"web-1", "db-2"},
+		"status":  200,
+		"message": "Connection refused by upstream",
+		"items": []interface{}{
+			map[string]interface{}{"name": "alpha"},
+			map[string]interface{}{"name": "beta"},
+		},
+	}
+
+	queries := map[string]bool{
+		`path:/\/api\/v[12]\/.*/`:         true,
+		`path:/\/api\/v[34]\/.*/`:         false,
+		`path:/api/`:                      false,
+		`path:/.*users/`:                  true,
+		`path:/.*users/ and status:200`:   true,
+		`agent:/Mozilla.*Linux.*/`:        true,
+		`agent:/mozilla.*/`:               false,
+		`tags:/db-\d+/`:                   true,
+		`tags:/app-.*/`:                   false,
+		`tags:(/app-.*/ or /web-.*/)`:     true,
+		`tags:(/web-.*/ and not /db-.*/)`: false,
+		`not path:/\/admin.*/`:            true,
+		`items:{name:/b.t./}`:             true,
+		`/web-\d/`:                        true,
+		`/nothing/`:                       false,
+		`*:/Connection.*/`:                true,
+		`p*:/\/api.*/`:                    true,
+		`path:"/api/v2/users"`:            true,
+		`missing:/.*/`:                    false,
+	}
+
+	data, _ := json.Marshal(obj)
+	jsonEvaluator, err := NewJSONEvaluator(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluators := map[string]Evaluator{
+		"map":     mustMapEvaluator(t, obj),
+		"reflect": NewReflectEvaluator(obj),
+		"json":    jsonEvaluator,
+	}
+	for query, expected := range queries {
+		expr := mustParse(t, query)
+		for name, evaluator := range evaluators {
+			res, err := expr.Match(evaluator)
+			if err != nil || res != expected {
+				t.Errorf("Unexpected result %v, %v of %s evaluator for %s. Expected: %v", res, err, name, query, expected)
+			}
+
+			explanation := expr.Explain(evaluator)
+			if explanation.Result != res || explanation.Err != nil {
+				t.Errorf("Explanation result %v, %v differs from match result for %s", explanation.Result, explanation.Err, query)
+			}
+		}
+	}
+
+	if _, err := mustParse(t, "status:/2.*/").Match(mustMapEvaluator(t, obj)); err == nil {
+		t.Error("Expected error for regular expression matched with a number")
+	}
+}
+
+func TestRegexpOptions(t *testing.T) {
+	type Item struct {
+		Agent   string
+		Message string
+	}
+	item := Item{Agent: "Mozilla/5.0", Message: "Connection refused"}
+
+	testMatch := func(query string, options ParseOptions, expected bool) {
+		t.Helper()
+		expr, err := ParseWithOptions(query, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res, err := expr.Match(NewReflectEvaluator(item)); err != nil || res != expected {
+			t.Errorf("Unexpected result %v, %v for %s. Expected: %v", res, err, query, expected)
+		}
+
+		match, err := CompileFor[Item](expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res, err := match(&item); err != nil || res != expected {
+			t.Errorf("Unexpected compiled result %v, %v for %s. Expected: %v", res, err, query, expected)
+		}
+	}
+
+	testMatch("Agent:/mozilla.*/", ParseOptions{}, false)
+	testMatch("Agent:/mozilla.*/", ParseOptions{StringMatching: FoldCase}, true)
+	testMatch("Agent:/MOZILLA.*/", ParseOptions{StringMatching: FoldCase}, true)
+	testMatch("Message:/refus.*/", ParseOptions{}, false)
+	testMatch("Message:/refus.*/", ParseOptions{Schema: Schema{"Message": {Type: FieldText}}}, true)
+	testMatch("Message:/Conn.*/", ParseOptions{Schema: Schema{"Message": {Type: FieldText}}}, false)
+
+	if _, err := CompileFor[struct{ Count int }](mustParse(t, "Count:/1.*/")); err == nil {
+		t.Error("Expected compilation error for regular expression matched with a number")
+	}
+}
+
+func TestRegexpParseErrors(t *testing.T) {
+	testParseError := func(query string, options ParseOptions, column int, token string) *ParseError {
+		t.Helper()
+		_, err := ParseWithOptions(query, options)
+		var parseError *ParseError
+		if !errors.As(err, &parseError) {
+			t.Fatalf("Expected *ParseError for %q, got %T: %v", query, err, err)
+		}
+		if parseError.Line != 1 || parseError.Column != column || parseError.Token != token {
+			t.Errorf("Unexpected error %d:%d %q for %q. Expected: 1:%d %q", parseError.Line, parseError.Column, parseError.Token, query, column, token)
+		}
+		return parseError
+	}
+
+	parseError := testParseError(`a:1 and path:/\/api\/v[12\/.*/`, ParseOptions{}, 14, `/\/api\/v[12\/.*/`)
+	var syntaxError *syntax.Error
+	if !errors.As(parseError, &syntaxError) || syntaxError.Code != syntax.ErrMissingBracket {
+		t.Errorf("Expected regexp syntax error, got %v", parseError)
+	}
+	testParseError("a:(1 or /(x/)", ParseOptions{}, 9, "/(x/")
+	testParseError("/a**/", ParseOptions{}, 1, "/a**/")
+	testParseError("a>/x/", ParseOptions{}, 3, "/x/")
+	testParseError("a:/x/ or b>=(1 or /y/)", ParseOptions{}, 19, "/y/")
+	testParseError("a:1 or path:/x/", ParseOptions{DisableRegexps: true}, 13, "/x/")
+	testParseError("a:/x/ and b:/(/", ParseOptions{DisableRegexps: true}, 13, "/(/")
+
+	if _, err := ParseWithOptions("a:1 or path:'/x/'", ParseOptions{DisableRegexps: true}); err != nil {
+		t.Errorf("Unexpected error for quoted value with slashes: %v", err)
+	}
+}
+
+func TestRegexpNodes(t *testing.T) {
+	for query, expected := range map[string]string{
+		`path:/\/api\/.*/`: `path:/\/api\/.*/`,
+		`a:(/x+/ or y)`:    `a:(/x+/ or y)`,
+		`/a\.b/`:           `/a\.b/`,
+		`a:'/x/'`:          `a:"/x/"`,
+	} {
+		expr := mustParse(t, query)
+		if actual := expr.String(); actual != expected {
+			t.Errorf("Unexpected string %s of %s. Expected: %s", actual, query, expected)
+		}
+		if actual := expr.Root().String(); actual != expected {
+			t.Errorf("Unexpected node string %s of %s. Expected: %s", actual, query, expected)
+		}
+	}
+
+	match := mustParse(t, `path:/\/api.*/`).Root().(*MatchNode)
+	if value := match.Value.(*ValueNode); !reflect.DeepEqual(value, &ValueNode{Text: `\/api.*`, Regexp: true}) {
+		t.Errorf("Unexpected value node %#v", value)
+	}
+
+	node := &MatchNode{Field: []string{"path"}, Operator: ":", Value: &ValueNode{Text: "/api/.*", Regexp: true}}
+	expr, err := NewExpression(node, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr.String() != `path:/\/api\/.*/` {
+		t.Errorf("Unexpected string %s of a regular expression with slashes", expr)
+	}
+	if res, err := expr.Match(mustMapEvaluator(t, map[string]interface{}{"path": "/api/v1"})); err != nil || !res {
+		t.Errorf("Unexpected match %v, %v of %s", res, err, expr)
+	}
+
+	if _, err := NewExpression(&TermNode{Value: &ValueNode{Text: "(", Regexp: true}}, ParseOptions{}); err == nil {
+		t.Error("Expected error for invalid regular expression node")
+	}
+	if _, err := NewExpression(node, ParseOptions{DisableRegexps: true}); err == nil {
+		t.Error("Expected error for disabled regular expressions")
+	}
+}
+
+func TestRegexpSQL(t *testing.T) {
+	for _, test := range []struct {
+		query    string
+		options  SQLOptions
+		expected string
+		args     []interface{}
+	}{
+		{`path:/\/api\/.*/`, SQLOptions{Dialect: PostgresDialect}, `"path" ~ $1`, []interface{}{`^(?:\/api\/.*)$`}},
+		{"a:(x or /y+/)", SQLOptions{Dialect: MySQLDialect}, "(`a` = ? OR `a` REGEXP ?)", []interface{}{"x", "^(?:y+)$"}},
+	} {
+		sql, args, err := mustParse(t, test.query).SQL(test.options)
+		if err != nil || sql != test.expected || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("Unexpected SQL %s %v, %v for %s. Expected: %s %v", sql, args, err, test.query, test.expected, test.args)
+		}
+	}
+
+	if _, _, err := mustParse(t, "a:/x/").SQL(SQLOptions{}); err == nil {
+		t.Error("Expected error for regular expression in a dialect without a regexp operator")
+	}
+}
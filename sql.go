@@ -0,0 +1,379 @@
+package gokql
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PlaceholderStyle defines how bind parameters are written in generated SQL.
+type PlaceholderStyle int
+
+const (
+	// PlaceholderQuestion writes every parameter as `?` (SQLite, MySQL).
+	PlaceholderQuestion PlaceholderStyle = iota
+	// PlaceholderDollar writes numbered parameters `$1`, `$2`, ... (PostgreSQL).
+	PlaceholderDollar
+)
+
+// SQLDialect describes the syntax differences between SQL databases which matter for generated filters.
+type SQLDialect struct {
+	Placeholder PlaceholderStyle
+	// QuoteIdentifier quotes a single identifier such as a column or a table name.
+	QuoteIdentifier func(name string) string
+	// RegexpOperator matches a column with a regular expression, such as `~` in PostgreSQL.
+	// Regular expression values are reported as errors if it is empty. The expressions are passed
+	// anchored as `^(?:pattern)$`, the database is responsible for interpreting their syntax.
+	RegexpOperator string
+}
+
+var (
+	PostgresDialect = SQLDialect{Placeholder: PlaceholderDollar, QuoteIdentifier: quoteIdentifierANSI, RegexpOperator: "~"}
+	// SQLiteDialect uses the REGEXP operator, which requires a regexp() function to be registered with SQLite.
+	SQLiteDialect = SQLDialect{Placeholder: PlaceholderQuestion, QuoteIdentifier: quoteIdentifierANSI, RegexpOperator: "REGEXP"}
+	MySQLDialect  = SQLDialect{Placeholder: PlaceholderQuestion, QuoteIdentifier: quoteIdentifierBacktick, RegexpOperator: "REGEXP"}
+)
+
+// SQLOptions controls translation of an expression into a SQL WHERE clause.
+type SQLOptions struct {
+	Dialect SQLDialect
+	// ColumnMapper maps a property path of the query to a column name. It can reject
+	// fields which are not allowed to be filtered by returning an error.
+	// By default the path elements are joined with dots, so `t.name` refers to column `name` of table `t`.
+	// Every dot-separated part of the returned name is quoted with Dialect.QuoteIdentifier.
+	ColumnMapper func(field []string) (string, error)
+	// ArgOffset is the number of bind parameters already present in the query the fragment is appended to.
+	// It is used to continue numbering of `$n` placeholders.
+	ArgOffset int
+}
+
+// likeEscape is the escape character used in generated LIKE patterns.
+// It is not a backslash, as backslashes are treated differently by SQL databases.
+const likeEscape = '!'
+
+// SQL translates the expression into a SQL WHERE clause fragment and a slice of bind arguments.
+// Values of the query are never interpolated into the fragment, all of them are passed as arguments.
+//
+// Wildcard values become LIKE patterns, regular expressions use the RegexpOperator of the dialect,
+// `or` lists of plain values become IN lists, other value lists become OR and AND conditions
+// and range operations become comparisons.
+// Nested sub-expressions and field name patterns have no SQL counterpart and are reported as errors.
+// Field-less terms are matched against the default fields of the expression.
+func (expression Expression) SQL(options SQLOptions) (string, []interface{}, error) {
+	if options.Dialect.QuoteIdentifier == nil {
+		options.Dialect.QuoteIdentifier = quoteIdentifierANSI
+	}
+	if options.ColumnMapper == nil {
+		options.ColumnMapper = func(field []string) (string, error) {
+			return strings.Join(field, "."), nil
+		}
+	}
+
+	builder := sqlBuilder{
+		options:       options,
+		defaultFields: expression.defaultFields,
+	}
+
+	if expression.ast == nil {
+		return "1 = 1", nil, nil
+	}
+
+	if err := builder.expression(expression.ast); err != nil {
+		return "", nil, err
+	}
+	if builder.err != nil {
+		return "", nil, builder.err
+	}
+
+	return builder.sql.String(), builder.args, nil
+}
+
+type sqlBuilder struct {
+	options       SQLOptions
+	defaultFields [][]string
+	sql           strings.Builder
+	args          []interface{}
+	// err is the first error of a value, which cannot be returned by the builder of the value
+	err error
+}
+
+func (b *sqlBuilder) expression(expr *expression) error {
+	return b.disjunction(expr.Expr)
+}
+
+func (b *sqlBuilder) disjunction(d disjunction) error {
+	if len(d.RightValues) == 0 {
+		return b.conjunction(d.LeftValue)
+	}
+
+	b.sql.WriteString("(")
+	if err := b.conjunction(d.LeftValue); err != nil {
+		return err
+	}
+	for _, right := range d.RightValues {
+		b.sql.WriteString(" OR ")
+		if err := b.conjunction(right); err != nil {
+			return err
+		}
+	}
+	b.sql.WriteString(")")
+
+	return nil
+}
+
+func (b *sqlBuilder) conjunction(c conjunction) error {
+	if len(c.RightValues) == 0 {
+		return b.subExpression(c.LeftValue)
+	}
+
+	b.sql.WriteString("(")
+	if err := b.subExpression(c.LeftValue); err != nil {
+		return err
+	}
+	for _, right := range c.RightValues {
+		b.sql.WriteString(" AND ")
+		if err := b.subExpression(right); err != nil {
+			return err
+		}
+	}
+	b.sql.WriteString(")")
+
+	return nil
+}
+
+func (b *sqlBuilder) subExpression(se subExpression) error {
+	if se.IsInverted {
+		b.sql.WriteString("NOT (")
+	}
+
+	var err error
+	if se.SubExpression != nil {
+		err = b.expression(se.SubExpression)
+	} else if se.FreeText != nil {
+		err = b.freeText(se.FreeText)
+	} else {
+		err = b.propertyMatch(se.Value)
+	}
+	if err != nil {
+		return err
+	}
+
+	if se.IsInverted {
+		b.sql.WriteString(")")
+	}
+
+	return nil
+}
+
+func (b *sqlBuilder) propertyMatch(prop *propertyMatch) error {
+	if prop.ValueSubExpression != nil {
+		return fmt.Errorf("nested query on %s is not supported in SQL", strings.Join(prop.Name, "."))
+	}
+
+	if prop.pattern != nil {
+		return fmt.Errorf("field name pattern %s is not supported in SQL", strings.Join(prop.Name, "."))
+	}
+
+	column, err := b.column(prop.Name)
+	if err != nil {
+		return err
+	}
+
+	if prop.AtomicValue != nil {
+		b.value(column, prop.Operation, prop.AtomicValue)
+		return nil
+	}
+
+	b.valueDisjunction(column, prop.Operation, prop.ValueList)
+	return nil
+}
+
+func (b *sqlBuilder) valueDisjunction(column string, operation string, d *valueDisjunction) {
+	if len(d.RightValues) == 0 {
+		b.valueConjunction(column, operation, d.LeftValue)
+		return
+	}
+
+	if values, ok := equalityValues(operation, d); ok {
+		b.sql.WriteString(column + " IN (")
+		for i, value := range values {
+			if i > 0 {
+				b.sql.WriteString(", ")
+			}
+			b.arg(value.Value)
+		}
+		b.sql.WriteString(")")
+		return
+	}
+
+	b.sql.WriteString("(")
+	for i, conj := range append([]valueConjunction{d.LeftValue}, d.RightValues...) {
+		if i > 0 {
+			b.sql.WriteString(" OR ")
+		}
+		b.valueConjunction(column, operation, conj)
+	}
+	b.sql.WriteString(")")
+}
+
+func (b *sqlBuilder) valueConjunction(column string, operation string, c valueConjunction) {
+	if len(c.RightValues) == 0 {
+		b.valueTerm(column, operation, c.LeftValue)
+		return
+	}
+
+	b.sql.WriteString("(")
+	for i, term := range append([]valueTerm{c.LeftValue}, c.RightValues...) {
+		if i > 0 {
+			b.sql.WriteString(" AND ")
+		}
+		b.valueTerm(column, operation, term)
+	}
+	b.sql.WriteString(")")
+}
+
+func (b *sqlBuilder) valueTerm(column string, operation string, v valueTerm) {
+	if v.IsInverted {
+		b.sql.WriteString("NOT (")
+	}
+
+	if v.ValueList != nil {
+		b.valueDisjunction(column, operation, v.ValueList)
+	} else {
+		b.value(column, operation, v.Value)
+	}
+
+	if v.IsInverted {
+		b.sql.WriteString(")")
+	}
+}
+
+func (b *sqlBuilder) value(column string, operation string, atomic *atomicValue) {
+	if operation == ":" {
+		b.equal(column, atomic)
+		return
+	}
+
+	b.sql.WriteString(column + " " + operation + " ")
+	b.arg(atomic.Value)
+}
+
+func (b *sqlBuilder) freeText(atomic *atomicValue) error {
+	if len(b.defaultFields) == 0 {
+		return fmt.Errorf("term %s has no field and there are no default fields to match it in SQL", atomic.Value)
+	}
+
+	if len(b.defaultFields) > 1 {
+		b.sql.WriteString("(")
+	}
+	for i, field := range b.defaultFields {
+		if i > 0 {
+			b.sql.WriteString(" OR ")
+		}
+
+		column, err := b.column(field)
+		if err != nil {
+			return err
+		}
+		b.equal(column, atomic)
+	}
+	if len(b.defaultFields) > 1 {
+		b.sql.WriteString(")")
+	}
+
+	return nil
+}
+
+// equalityValues returns the values of a value list consisting only of alternatives
+// compared for equality without patterns, which can be matched with IN.
+func equalityValues(operation string, d *valueDisjunction) ([]*atomicValue, bool) {
+	if operation != ":" {
+		return nil, false
+	}
+
+	var values []*atomicValue
+	for _, conj := range append([]valueConjunction{d.LeftValue}, d.RightValues...) {
+		term := conj.LeftValue
+		if len(conj.RightValues) > 0 || term.IsInverted || term.Value == nil ||
+			term.Value.wildcard.isPattern() || term.Value.regexp != nil {
+			return nil, false
+		}
+		values = append(values, term.Value)
+	}
+	return values, true
+}
+
+func (b *sqlBuilder) equal(column string, atomic *atomicValue) {
+	if atomic.isExistence() {
+		b.sql.WriteString(column + " IS NOT NULL")
+		return
+	}
+
+	if atomic.regexp != nil {
+		if b.options.Dialect.RegexpOperator == "" {
+			if b.err == nil {
+				b.err = fmt.Errorf("regular expression %s is not supported by the SQL dialect", atomic)
+			}
+			return
+		}
+		b.sql.WriteString(column + " " + b.options.Dialect.RegexpOperator + " ")
+		b.arg(anchorRegexp(atomic.Value))
+		return
+	}
+
+	if atomic.wildcard.isPattern() {
+		b.sql.WriteString(column + " LIKE ")
+		b.arg(atomic.wildcard.format("%", escapeLike))
+		b.sql.WriteString(" ESCAPE '" + string(likeEscape) + "'")
+		return
+	}
+
+	b.sql.WriteString(column + " = ")
+	b.arg(atomic.Value)
+}
+
+func (b *sqlBuilder) column(field []string) (string, error) {
+	name, err := b.options.ColumnMapper(field)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", errors.New("empty column name for field " + strings.Join(field, "."))
+	}
+
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = b.options.Dialect.QuoteIdentifier(part)
+	}
+	return strings.Join(parts, "."), nil
+}
+
+func (b *sqlBuilder) arg(value interface{}) {
+	b.args = append(b.args, value)
+	if b.options.Dialect.Placeholder == PlaceholderDollar {
+		b.sql.WriteString("$" + strconv.Itoa(b.options.ArgOffset+len(b.args)))
+	} else {
+		b.sql.WriteString("?")
+	}
+}
+
+// escapeLike escapes LIKE special characters.
+func escapeLike(value string) string {
+	var builder strings.Builder
+	for _, r := range value {
+		if r == '%' || r == '_' || r == likeEscape {
+			builder.WriteRune(likeEscape)
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+func quoteIdentifierANSI(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteIdentifierBacktick(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
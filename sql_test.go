@@ -0,0 +1,84 @@
+package gokql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSQL(t *testing.T) {
+	testSQL := func(query string, options SQLOptions, expectedSQL string, expectedArgs ...interface{}) {
+		t.Helper()
+		expr, err := ParseWithOptions(query, ParseOptions{DefaultFields: []string{"message", "level"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sql, args, err := expr.SQL(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sql != expectedSQL {
+			t.Errorf("Unexpected SQL for %s: %s. Expected: %s", query, sql, expectedSQL)
+		}
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("Unexpected arguments for %s: %v. Expected: %v", query, args, expectedArgs)
+		}
+	}
+
+	postgres := SQLOptions{Dialect: PostgresDialect}
+	sqlite := SQLOptions{Dialect: SQLiteDialect}
+
+	testSQL("status:200", postgres, `"status" = $1`, "200")
+	testSQL("status:200", sqlite, `"status" = ?`, "200")
+	testSQL("status:200", SQLOptions{Dialect: MySQLDialect}, "`status` = ?", "200")
+	testSQL("t.status:200", postgres, `"t"."status" = $1`, "200")
+	testSQL("a>1 and b<=2", postgres, `("a" > $1 AND "b" <= $2)`, "1", "2")
+	testSQL("a:1 or b:2 and not c:3", postgres, `("a" = $1 OR ("b" = $2 AND NOT ("c" = $3)))`, "1", "2", "3")
+	testSQL("name:web*", postgres, `"name" LIKE $1 ESCAPE '!'`, "web%")
+	testSQL(`name:*50\%_off\!*`, postgres, `"name" LIKE $1 ESCAPE '!'`, "%50!%!_off!!%")
+	testSQL("name:*", postgres, `"name" IS NOT NULL`)
+	testSQL("a:(1 or 2 or 3)", postgres, `"a" IN ($1, $2, $3)`, "1", "2", "3")
+	testSQL("a:(1 and 2)", postgres, `("a" = $1 AND "a" = $2)`, "1", "2")
+	testSQL("a:(1 or (2* and not 23))", postgres, `("a" = $1 OR ("a" LIKE $2 ESCAPE '!' AND NOT ("a" = $3)))`, "1", "2%", "23")
+	testSQL("a>(1 or 5)", postgres, `("a" > $1 OR "a" > $2)`, "1", "5")
+	testSQL("a:(1 or 2*)", postgres, `("a" = $1 OR "a" LIKE $2 ESCAPE '!')`, "1", "2%")
+	testSQL("error", postgres, `("message" = $1 OR "level" = $2)`, "error", "error")
+	testSQL("a:1", SQLOptions{Dialect: PostgresDialect, ArgOffset: 2}, `"a" = $3`, "1")
+	testSQL(`name:"x' OR 1=1 --"`, sqlite, `"name" = ?`, "x' OR 1=1 --")
+
+	mapped := SQLOptions{
+		Dialect: PostgresDialect,
+		ColumnMapper: func(field []string) (string, error) {
+			if len(field) == 2 && field[0] == "host" && field[1] == "name" {
+				return "hosts.host_name", nil
+			}
+			return "", errors.New("unknown field")
+		},
+	}
+	quoted := SQLOptions{
+		Dialect: SQLiteDialect,
+		ColumnMapper: func(field []string) (string, error) {
+			return `we"ird`, nil
+		},
+	}
+	testSQL("a:1", quoted, `"we""ird" = ?`, "1")
+	testSQL("host.name:web", mapped, `"hosts"."host_name" = $1`, "web")
+
+	testSQLError := func(query string, options SQLOptions) {
+		t.Helper()
+		expr, err := Parse(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := expr.SQL(options); err == nil {
+			t.Errorf("Expected error for %s", query)
+		}
+	}
+
+	testSQLError("other:1", mapped)
+	testSQLError("items:{name:a}", postgres)
+	testSQLError("error", postgres)
+}
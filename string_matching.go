@@ -0,0 +1,46 @@
+package gokql
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// apply converts a string to the form in which strings are compared by the matching mode.
+func (m StringMatching) apply(s string) string {
+	if m == 0 {
+		return s
+	}
+	if m&NormalizeUnicode != 0 {
+		s = norm.NFC.String(s)
+	}
+	if m&FoldCase != 0 {
+		s = foldCase(s)
+	}
+	return s
+}
+
+// foldCase replaces every character with the smallest character of its case folding orbit,
+// so two strings are equal after folding exactly when strings.EqualFold reports them equal.
+func foldCase(s string) string {
+	return strings.Map(foldRune, s)
+}
+
+func foldRune(r rune) rune {
+	if r < utf8.RuneSelf {
+		if 'a' <= r && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}
+
+	folded := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < folded {
+			folded = f
+		}
+	}
+	return folded
+}
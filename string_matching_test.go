@@ -0,0 +1,105 @@
+package gokql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFoldCase(t *testing.T) {
+	pairs := [][2]string{
+		{"error", "ERROR"},
+		{"Straße", "STRAßE"},
+		{"kelvin", "\u212aelvin"},
+		{"ſtop", "STOP"},
+		{"ΣΊΣΥΦΟΣ", "σίσυφος"},
+		{"a*b", "A*B"},
+	}
+
+	for _, pair := range pairs {
+		if !strings.EqualFold(pair[0], pair[1]) {
+			t.Fatalf("Test pair %q, %q is not equal with strings.EqualFold", pair[0], pair[1])
+		}
+		if foldCase(pair[0]) != foldCase(pair[1]) {
+			t.Errorf("Folded strings %q and %q differ", foldCase(pair[0]), foldCase(pair[1]))
+		}
+	}
+
+	if foldCase("strasse") == foldCase("straße") {
+		t.Error("Full case folding is not expected")
+	}
+}
+
+func TestStringMatching(t *testing.T) {
+	composed := "caf\u00e9"
+	decomposed := "cafe\u0301"
+	obj := map[string]interface{}{
+		"level":    "ERROR",
+		"name":     decomposed,
+		"id":       "AbC",
+		"city":     "Z\u00fcrich",
+		"tags":     []string{"Prod", "EU"},
+		"nested":   map[string]interface{}{"level": "Warn"},
+		"messages": "Connection Refused",
+	}
+
+	exact := ParseOptions{}
+	fold := ParseOptions{StringMatching: FoldCase}
+	normalize := ParseOptions{StringMatching: NormalizeUnicode}
+	both := ParseOptions{StringMatching: FoldCase | NormalizeUnicode}
+
+	testExprWithOptions(t, "level:error", exact, obj, false)
+	testExprWithOptions(t, "level:error", fold, obj, true)
+	testExprWithOptions(t, "level:err*", fold, obj, true)
+	testExprWithOptions(t, "level:*RoR", fold, obj, true)
+	testExprWithOptions(t, "level:(warn or error)", fold, obj, true)
+	testExprWithOptions(t, "tags:prod and tags:eu", fold, obj, true)
+	testExprWithOptions(t, "nested:{level:WARN}", fold, obj, true)
+	testExprWithOptions(t, "error", fold, obj, true)
+	testExprWithOptions(t, "'connection refused'", fold, obj, true)
+
+	testExprWithOptions(t, "level>d", exact, obj, false)
+	testExprWithOptions(t, "level>d", fold, obj, true)
+	testExprWithOptions(t, "level<=error", fold, obj, true)
+	testExprWithOptions(t, "level<f", fold, obj, true)
+	testExprWithOptions(t, "level>=ERRORS", fold, obj, false)
+
+	testExprWithOptions(t, "name:'"+composed+"'", exact, obj, false)
+	testExprWithOptions(t, "name:'"+composed+"'", normalize, obj, true)
+	testExprWithOptions(t, "name:caf\\\u00e9*", normalize, obj, true)
+	testExprWithOptions(t, "name:'CAF\u00c9'", normalize, obj, false)
+	testExprWithOptions(t, "name:'CAF\u00c9'", both, obj, true)
+	testExprWithOptions(t, "name:'CAFE\u0301'", both, obj, true)
+	testExprWithOptions(t, "city:'zu\u0308rich'", both, obj, true)
+
+	perField := ParseOptions{
+		StringMatching: FoldCase,
+		Schema: Schema{
+			"id":   {StringMatching: ExactStrings},
+			"name": {StringMatching: NormalizeUnicode},
+		},
+	}
+	testExprWithOptions(t, "level:error", perField, obj, true)
+	testExprWithOptions(t, "id:abc", perField, obj, false)
+	testExprWithOptions(t, "id:AbC", perField, obj, true)
+	testExprWithOptions(t, "name:'"+composed+"'", perField, obj, true)
+	testExprWithOptions(t, "name:'CAF\u00c9'", perField, obj, false)
+
+	onlyField := ParseOptions{Schema: Schema{"level": {StringMatching: FoldCase}}}
+	testExprWithOptions(t, "level:error", onlyField, obj, true)
+	testExprWithOptions(t, "id:abc", onlyField, obj, false)
+
+	type item struct {
+		Level string
+	}
+	expr, err := ParseWithOptions("Level:error", fold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	match, err := CompileFor[item](expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := match(&item{Level: "Error"}); err != nil || !res {
+		t.Errorf("Unexpected compiled match result: %v, %v", res, err)
+	}
+}
@@ -0,0 +1,209 @@
+package gokql
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultFieldTags are the struct tags property names are taken from by default, in order of precedence.
+var defaultFieldTags = []string{"kql", "json"}
+
+var defaultTags = strings.Join(defaultFieldTags, ",")
+
+// structField is a field of a struct type as it is referred to in queries.
+type structField struct {
+	name string
+	// goName is the name of the field in Go, which is an alias of a tag name.
+	goName string
+	index  []int
+	tagged bool
+}
+
+// structFields are the fields of a struct type which can be referred to in queries,
+// in the order of their declaration.
+type structFields struct {
+	list   []structField
+	byName map[string]int
+	// byGoName maps Go names of fields to the first field with such a name
+	byGoName map[string]int
+	// byFoldedName maps lowercased names and Go names to the first field with such a name
+	byFoldedName map[string]int
+}
+
+type structFieldsKey struct {
+	structType reflect.Type
+	tags       string
+}
+
+var structFieldsCache sync.Map
+
+// cachedStructFields returns the fields of the struct type named by the first of the
+// comma-separated tags a field has.
+func cachedStructFields(structType reflect.Type, tags string) *structFields {
+	key := structFieldsKey{structType, tags}
+	if fields, ok := structFieldsCache.Load(key); ok {
+		return fields.(*structFields)
+	}
+
+	fields, _ := structFieldsCache.LoadOrStore(key, newStructFields(structType, strings.Split(tags, ",")))
+	return fields.(*structFields)
+}
+
+// lookup finds a field by its name, falling back to the Go name of a field, so that queries
+// written before the field was tagged keep working, and then to a case-insensitive match.
+func (f *structFields) lookup(name string) (structField, bool) {
+	if i, ok := f.byName[name]; ok {
+		return f.list[i], true
+	}
+	if i, ok := f.byGoName[name]; ok {
+		return f.list[i], true
+	}
+	if i, ok := f.byFoldedName[strings.ToLower(name)]; ok {
+		return f.list[i], true
+	}
+	return structField{}, false
+}
+
+func (f *structFields) names() []string {
+	names := make([]string, len(f.list))
+	for i, field := range f.list {
+		names[i] = field.name
+	}
+	return names
+}
+
+// newStructFields collects the exported fields of a struct type. Fields of embedded structs without
+// a tag name are promoted like in encoding/json: a field at a shallower depth hides deeper fields
+// with the same name, a tagged field wins among fields at the same depth, and other conflicting
+// fields are omitted.
+func newStructFields(structType reflect.Type, tags []string) *structFields {
+	fields := &structFields{byName: map[string]int{}, byGoName: map[string]int{}, byFoldedName: map[string]int{}}
+	if structType.Kind() != reflect.Struct {
+		return fields
+	}
+
+	type level struct {
+		structType reflect.Type
+		index      []int
+	}
+
+	hidden := map[string]bool{}
+	visited := map[reflect.Type]bool{}
+	current := []level{{structType, nil}}
+	for len(current) > 0 {
+		var next []level
+		var depth []structField
+		for _, l := range current {
+			if visited[l.structType] {
+				continue
+			}
+			visited[l.structType] = true
+
+			for i := 0; i < l.structType.NumField(); i++ {
+				field := l.structType.Field(i)
+				name, tagged, ignored := fieldTagName(field, tags)
+				if ignored {
+					continue
+				}
+
+				index := append(append([]int(nil), l.index...), i)
+				if field.Anonymous && !tagged {
+					embedded, _ := indirectType(field.Type)
+					if embedded.Kind() == reflect.Struct {
+						next = append(next, level{embedded, index})
+						continue
+					}
+				}
+				if !field.IsExported() {
+					continue
+				}
+
+				depth = append(depth, structField{name, field.Name, index, tagged})
+			}
+		}
+
+		dominant := dominantFields(depth, hidden)
+		for _, field := range depth {
+			hidden[field.name] = true
+		}
+		fields.list = append(fields.list, dominant...)
+		current = next
+	}
+
+	sort.Slice(fields.list, func(i, j int) bool {
+		return lessIndex(fields.list[i].index, fields.list[j].index)
+	})
+	for i, field := range fields.list {
+		fields.byName[field.name] = i
+		if _, ok := fields.byGoName[field.goName]; !ok {
+			fields.byGoName[field.goName] = i
+		}
+		folded := strings.ToLower(field.name)
+		if _, ok := fields.byFoldedName[folded]; !ok {
+			fields.byFoldedName[folded] = i
+		}
+	}
+	for i, field := range fields.list {
+		folded := strings.ToLower(field.goName)
+		if _, ok := fields.byFoldedName[folded]; !ok {
+			fields.byFoldedName[folded] = i
+		}
+	}
+	return fields
+}
+
+// dominantFields returns the fields of one depth which are not hidden by shallower fields
+// and have no conflicting field at the same depth.
+func dominantFields(depth []structField, hidden map[string]bool) []structField {
+	var result []structField
+	for i, field := range depth {
+		if hidden[field.name] {
+			continue
+		}
+
+		dominant := true
+		for j, other := range depth {
+			if i == j || other.name != field.name {
+				continue
+			}
+			if !field.tagged || other.tagged {
+				dominant = false
+				break
+			}
+		}
+		if dominant {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+func lessIndex(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// fieldTagName returns the name of the field from the first of the tags the field has,
+// or the name of the field if it has none of them.
+func fieldTagName(field reflect.StructField, tags []string) (name string, tagged bool, ignored bool) {
+	for _, tag := range tags {
+		value, ok := field.Tag.Lookup(tag)
+		if !ok {
+			continue
+		}
+		if value == "-" {
+			return "", false, true
+		}
+		if name, _, _ := strings.Cut(value, ","); name != "" {
+			return name, true, false
+		}
+		break
+	}
+	return field.Name, false, false
+}
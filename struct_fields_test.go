@@ -0,0 +1,176 @@
+package gokql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type taggedHost struct {
+	Name string `json:"name"`
+	IP   string `json:"ip"`
+}
+
+type taggedMeta struct {
+	Region  string `json:"region"`
+	Version int    `json:"version"`
+}
+
+type taggedAudit struct {
+	Version int    `json:"version"`
+	User    string `json:"user" kql:"audit_user"`
+}
+
+type taggedEvent struct {
+	taggedMeta
+	*taggedAudit
+	StatusCode int        `json:"status_code"`
+	Host       taggedHost `json:"host"`
+	Message    string     `json:"message,omitempty" db:"msg"`
+	Secret     string     `kql:"-"`
+	Internal   string     `json:"-"`
+	Level      string
+	Trace      string `json:",omitempty"`
+	hidden     string
+}
+
+func TestReflectEvaluatorTags(t *testing.T) {
+	event := taggedEvent{
+		taggedMeta:  taggedMeta{Region: "eu", Version: 1},
+		taggedAudit: &taggedAudit{Version: 2, User: "alice"},
+		StatusCode:  503,
+		Host:        taggedHost{Name: "web-1", IP: "10.1.2.3"},
+		Message:     "refused",
+		Secret:      "s3cr3t",
+		Internal:    "internal",
+		Level:       "error",
+		Trace:       "abc",
+		hidden:      "hidden",
+	}
+
+	queries := map[string]bool{
+		"status_code:503":                        true,
+		"STATUS_CODE:503":                        true,
+		"StatusCode:503":                         true,
+		`host.name:web-1 and host.ip:"10.1.2.3"`: true,
+		"host:{Name:web-1}":                      true,
+		"message:refused":                        true,
+		"level:error and Level:error":            true,
+		"Trace:abc":                              true,
+		"region:eu":                              true,
+		"version:*":                              false,
+		"audit_user:alice":                       true,
+		"user:alice":                             true,
+		"User:alice and Message:refused":         true,
+		"Secret:*":                               false,
+		"Internal:*":                             false,
+		"s3cr3t or internal or hidden":           false,
+		"alice":                                  true,
+	}
+	for query, expected := range queries {
+		testExpr(t, query, NewReflectEvaluator(event), expected)
+
+		match, err := CompileFor[taggedEvent](mustParse(t, query))
+		if err != nil {
+			if expected {
+				t.Errorf("Unexpected compile error for %s: %v", query, err)
+			}
+			continue
+		}
+		if res, err := match(&event); err != nil || res != expected {
+			t.Errorf("Unexpected compiled result %v, %v for %s. Expected: %v", res, err, query, expected)
+		}
+	}
+
+	hidden := mustParse(t, "hidden:*")
+	var unexported *UnexportedFieldError
+	if res, err := hidden.Match(NewReflectEvaluator(event)); res || !errors.As(err, &unexported) || unexported.Field != "hidden" {
+		t.Errorf("Unexpected result %v, %v for an unexported field", res, err)
+	}
+	if _, err := CompileFor[taggedEvent](hidden); !errors.As(err, &unexported) || unexported.Field != "hidden" {
+		t.Errorf("Unexpected compile error %v for an unexported field", err)
+	}
+
+	keys, _ := NewReflectEvaluator(event).Keys()
+	expectedKeys := []string{"region", "audit_user", "status_code", "host", "message", "Level", "Trace"}
+	if !reflect.DeepEqual(keys, expectedKeys) {
+		t.Errorf("Unexpected keys %q. Expected: %q", keys, expectedKeys)
+	}
+
+	event.taggedAudit = nil
+	testExpr(t, "audit_user:*", NewReflectEvaluator(event), false)
+	testExpr(t, "region:eu", NewReflectEvaluator(event), true)
+
+	testExpr(t, "msg:refused and Host:{Name:web-1}", NewReflectEvaluatorWithTags(event, "db"), true)
+	testExpr(t, "message:refused and Message:refused", NewReflectEvaluatorWithTags(event, "db"), true)
+	testExpr(t, "Internal:internal", NewReflectEvaluatorWithTags(event, "db"), true)
+}
+
+func TestGoFieldNamesOfTaggedFields(t *testing.T) {
+	type Item struct {
+		StatusCode int `json:"status_code"`
+	}
+	item := Item{StatusCode: 200}
+
+	for query, expected := range map[string]bool{
+		"StatusCode:200":      true,
+		"status_code:200":     true,
+		"not StatusCode:*":    false,
+		"StatusCode:404":      false,
+		"statuscode:200":      true,
+		"not status_code:*":   false,
+		"StatusCode>=200":     true,
+		"status_code:(200)":   true,
+		"StatusCode:(1 or 2)": false,
+	} {
+		testExpr(t, query, NewReflectEvaluator(item), expected)
+
+		match, err := CompileFor[Item](mustParse(t, query))
+		if err != nil {
+			t.Errorf("Unexpected compile error for %s: %v", query, err)
+			continue
+		}
+		if res, err := match(&item); err != nil || res != expected {
+			t.Errorf("Unexpected compiled result %v, %v for %s. Expected: %v", res, err, query, expected)
+		}
+	}
+}
+
+func TestStructFieldConflicts(t *testing.T) {
+	type A struct{ Name, Left string }
+	type B struct{ Name, Right string }
+	type C struct {
+		Name string `json:"name"`
+	}
+	type Conflict struct {
+		A
+		B
+	}
+	type Tagged struct {
+		A
+		C
+	}
+	type Shadowed struct {
+		A
+		Name int
+	}
+
+	if _, ok := cachedStructFields(reflect.TypeOf(Conflict{}), defaultTags).lookup("Name"); ok {
+		t.Error("Expected conflicting promoted fields to be omitted")
+	}
+	fields := cachedStructFields(reflect.TypeOf(Conflict{}), defaultTags)
+	if !reflect.DeepEqual(fields.names(), []string{"Left", "Right"}) {
+		t.Errorf("Unexpected fields %q", fields.names())
+	}
+
+	if field, ok := cachedStructFields(reflect.TypeOf(Tagged{}), defaultTags).lookup("name"); !ok || !reflect.DeepEqual(field.index, []int{1, 0}) {
+		t.Errorf("Expected the tagged field to win, got %v", field)
+	}
+	if field, ok := cachedStructFields(reflect.TypeOf(Tagged{}), defaultTags).lookup("Name"); !ok || !reflect.DeepEqual(field.index, []int{0, 0}) {
+		t.Errorf("Expected exact name to win over case-insensitive match, got %v", field)
+	}
+
+	if field, ok := cachedStructFields(reflect.TypeOf(Shadowed{}), defaultTags).lookup("Name"); !ok || !reflect.DeepEqual(field.index, []int{1}) {
+		t.Errorf("Expected the shallow field to win, got %v", field)
+	}
+}
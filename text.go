@@ -0,0 +1,180 @@
+package gokql
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Analyzer splits the value of a text property into the tokens which queries are matched against.
+// The same analyzer is applied to query values, so it has to be deterministic.
+type Analyzer interface {
+	Analyze(text string) []string
+}
+
+// Tokenizer splits text into tokens.
+type Tokenizer func(text string) []string
+
+// TokenFilter transforms a sequence of tokens, for example by lowercasing or removing some of them.
+type TokenFilter func(tokens []string) []string
+
+type analyzer struct {
+	tokenizer Tokenizer
+	filters   []TokenFilter
+}
+
+// NewAnalyzer returns an analyzer which splits text with the tokenizer and applies the filters in order.
+func NewAnalyzer(tokenizer Tokenizer, filters ...TokenFilter) Analyzer {
+	return analyzer{tokenizer: tokenizer, filters: filters}
+}
+
+func (a analyzer) Analyze(text string) []string {
+	tokens := a.tokenizer(text)
+	for _, filter := range a.filters {
+		tokens = filter(tokens)
+	}
+	return tokens
+}
+
+// StandardAnalyzer splits text into words of letters and digits and lowercases them.
+// It is used for text fields which have no analyzer in their FieldSchema.
+var StandardAnalyzer = NewAnalyzer(WordTokenizer, LowercaseFilter)
+
+// WordTokenizer splits text into words of letters and digits. Every other character separates words.
+func WordTokenizer(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// LowercaseFilter lowercases every token.
+func LowercaseFilter(tokens []string) []string {
+	for i, token := range tokens {
+		tokens[i] = strings.ToLower(token)
+	}
+	return tokens
+}
+
+// StopWordsFilter returns a filter which removes the given words. Words are compared
+// exactly, so the filter usually follows LowercaseFilter and the words are lowercase.
+func StopWordsFilter(words ...string) TokenFilter {
+	stopWords := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		stopWords[word] = struct{}{}
+	}
+
+	return func(tokens []string) []string {
+		result := tokens[:0]
+		for _, token := range tokens {
+			if _, ok := stopWords[token]; !ok {
+				result = append(result, token)
+			}
+		}
+		return result
+	}
+}
+
+// ================ TEXT  ====================
+
+// textTypeHandler compares string values of text fields token by token. A quoted query value
+// matches a property containing its tokens as a phrase, in the same order and without gaps.
+// An unquoted value matches a property containing any of its tokens, and an unquoted wildcard
+// matches a property containing a token which matches it. Range operations compare whole strings.
+type textTypeHandler struct {
+	analyzer Analyzer
+	matching StringMatching
+	phrase   bool
+	wildcard wildcard
+}
+
+func newTextTypeHandler(atomic *atomicValue) textTypeHandler {
+	field := atomic.comparers.fieldSchema()
+	handler := textTypeHandler{
+		analyzer: field.Analyzer,
+		matching: atomic.comparers.stringMatching(),
+		phrase:   atomic.quoted,
+	}
+	if handler.analyzer == nil {
+		handler.analyzer = StandardAnalyzer
+	}
+
+	if !handler.phrase && atomic.wildcard.isPattern() {
+		// every part of the pattern is analyzed on its own, so `Conn*` matches the token `connection`
+		handler.wildcard = atomic.wildcard.mapParts(func(part string) string {
+			return strings.Join(handler.analyzer.Analyze(handler.matching.apply(part)), " ")
+		})
+	}
+	return handler
+}
+
+// textQuery is a query value converted by textTypeHandler: the tokens of the value
+// for equality and the value itself for range operations.
+type textQuery struct {
+	tokens []string
+	value  string
+}
+
+func (h textTypeHandler) convert(value string) (result interface{}, err error) {
+	value = h.matching.apply(value)
+	return textQuery{tokens: h.analyzer.Analyze(value), value: value}, nil
+}
+
+func (h textTypeHandler) equal(left interface{}, right interface{}) bool {
+	tokens := h.analyzer.Analyze(h.matching.apply(left.(string)))
+	query := right.(textQuery)
+
+	switch {
+	case h.wildcard.isPattern():
+		for _, token := range tokens {
+			if h.wildcard.Match(token) {
+				return true
+			}
+		}
+		return false
+	case h.phrase:
+		return containsPhrase(tokens, query.tokens)
+	default:
+		for _, token := range tokens {
+			for _, queryToken := range query.tokens {
+				if token == queryToken {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+func (h textTypeHandler) greater(left interface{}, right interface{}) bool {
+	return h.matching.apply(left.(string)) > right.(textQuery).value
+}
+
+func (h textTypeHandler) less(left interface{}, right interface{}) bool {
+	return h.matching.apply(left.(string)) < right.(textQuery).value
+}
+
+func (h textTypeHandler) greaterOrEqual(left interface{}, right interface{}) bool {
+	return h.matching.apply(left.(string)) >= right.(textQuery).value
+}
+
+func (h textTypeHandler) lessOrEqual(left interface{}, right interface{}) bool {
+	return h.matching.apply(left.(string)) <= right.(textQuery).value
+}
+
+// containsPhrase reports whether the phrase occurs in the tokens as a contiguous sequence.
+// An empty phrase, for example one consisting of stop words only, matches nothing.
+func containsPhrase(tokens []string, phrase []string) bool {
+	if len(phrase) == 0 {
+		return false
+	}
+
+	for start := 0; start+len(phrase) <= len(tokens); start++ {
+		i := 0
+		for i < len(phrase) && tokens[start+i] == phrase[i] {
+			i++
+		}
+		if i == len(phrase) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package gokql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzer(t *testing.T) {
+	testAnalyze := func(analyzer Analyzer, text string, expected []string) {
+		t.Helper()
+		if actual := analyzer.Analyze(text); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Unexpected tokens %q for %q. Expected: %q", actual, text, expected)
+		}
+	}
+
+	testAnalyze(StandardAnalyzer, "Connection refused: dial tcp 10.0.0.1:5432", []string{"connection", "refused", "dial", "tcp", "10", "0", "0", "1", "5432"})
+	testAnalyze(StandardAnalyzer, "Zürich-West", []string{"zürich", "west"})
+	testAnalyze(StandardAnalyzer, " ,;", []string{})
+
+	stopWords := NewAnalyzer(WordTokenizer, LowercaseFilter, StopWordsFilter("the", "a", "of"))
+	testAnalyze(stopWords, "The end of a Story", []string{"end", "story"})
+}
+
+func TestTextFields(t *testing.T) {
+	obj := map[string]interface{}{
+		"message": "Connection refused by the upstream server",
+		"lines":   []string{"first line", "Second Line"},
+		"level":   "Error",
+		"nested":  map[string]interface{}{"text": "Disk is full"},
+	}
+	options := ParseOptions{
+		Schema: Schema{
+			"message":     {Type: FieldText},
+			"lines":       {Type: FieldText},
+			"level":       {Type: FieldKeyword},
+			"nested.text": {Type: FieldText},
+		},
+	}
+
+	testExprWithOptions(t, `message:"connection refused"`, options, obj, true)
+	testExprWithOptions(t, `message:'UPSTREAM server'`, options, obj, true)
+	testExprWithOptions(t, `message:"refused connection"`, options, obj, false)
+	testExprWithOptions(t, `message:"connection by"`, options, obj, false)
+	testExprWithOptions(t, `message:"refused, by the"`, options, obj, true)
+	testExprWithOptions(t, `message:" "`, options, obj, false)
+	testExprWithOptions(t, "message:refused", options, obj, true)
+	testExprWithOptions(t, "message:Server", options, obj, true)
+	testExprWithOptions(t, "message:serve", options, obj, false)
+	testExprWithOptions(t, "message:(timeout or upstream)", options, obj, true)
+	testExprWithOptions(t, "message:(timeout and upstream)", options, obj, false)
+	testExprWithOptions(t, "message:Conn*", options, obj, true)
+	testExprWithOptions(t, "message:*stream", options, obj, true)
+	testExprWithOptions(t, "message:refused*server", options, obj, false)
+	testExprWithOptions(t, "message:*", options, obj, true)
+	testExprWithOptions(t, "message>Con", options, obj, true)
+	testExprWithOptions(t, "message<con", options, obj, true)
+
+	testExprWithOptions(t, `lines:"second line"`, options, obj, true)
+	testExprWithOptions(t, `lines:"line second"`, options, obj, false)
+	testExprWithOptions(t, "nested:{text:disk}", options, obj, true)
+	testExprWithOptions(t, "level:error", options, obj, false)
+	testExprWithOptions(t, "level:Error", options, obj, true)
+
+	stopWords := ParseOptions{
+		Schema: Schema{
+			"message": {Type: FieldText, Analyzer: NewAnalyzer(WordTokenizer, LowercaseFilter, StopWordsFilter("by", "the"))},
+		},
+	}
+	testExprWithOptions(t, `message:"refused upstream"`, stopWords, obj, true)
+	testExprWithOptions(t, `message:"the"`, stopWords, obj, false)
+	testExprWithOptions(t, "message:the", stopWords, obj, false)
+
+	expr, err := NewExpression(&MatchNode{Field: []string{"message"}, Operator: ":", Value: &ValueNode{Text: "refused connection", Quoted: true}}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluator, _ := NewMapEvaluator(obj)
+	if res, err := expr.Match(evaluator); err != nil || res {
+		t.Errorf("Unexpected match result for a quoted value node: %v, %v", res, err)
+	}
+
+	type logEntry struct {
+		Message string
+	}
+	expr, err = ParseWithOptions(`Message:"connection refused"`, ParseOptions{Schema: Schema{"Message": {Type: FieldText}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	match, err := CompileFor[logEntry](expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := match(&logEntry{Message: "ERROR: Connection refused"}); err != nil || !res {
+		t.Errorf("Unexpected compiled match result: %v, %v", res, err)
+	}
+}
@@ -1,10 +1,45 @@
 package gokql
 
 import (
+	"fmt"
+	"reflect"
 	"strconv"
 	"time"
 )
 
+// TypeHandler compares property values of a custom type, such as a version or a money amount,
+// with query values. Handlers are registered per property type with ParseOptions.TypeHandlers.
+type TypeHandler interface {
+	// Parse converts a query value to the value passed to Compare.
+	Parse(value string) (interface{}, error)
+	// Compare returns a negative number if the property is less than the parsed query value,
+	// zero if they are equal and a positive number if the property is greater.
+	Compare(property interface{}, value interface{}) int
+}
+
+// TypeHandlers maps property types to handlers of their values.
+// A handler registered for a type takes precedence over the built-in handling of the type.
+type TypeHandlers map[reflect.Type]TypeHandler
+
+// NewTypeHandler creates a TypeHandler for properties of type T from a function parsing
+// query values and a function comparing two values of T.
+func NewTypeHandler[T any](parse func(value string) (T, error), compare func(property T, value T) int) TypeHandler {
+	return funcTypeHandler[T]{parse, compare}
+}
+
+type funcTypeHandler[T any] struct {
+	parse   func(value string) (T, error)
+	compare func(property T, value T) int
+}
+
+func (h funcTypeHandler[T]) Parse(value string) (interface{}, error) {
+	return h.parse(value)
+}
+
+func (h funcTypeHandler[T]) Compare(property interface{}, value interface{}) int {
+	return h.compare(property.(T), value.(T))
+}
+
 type typeHandler interface {
 	convert(value string) (result interface{}, err error)
 	equal(left interface{}, right interface{}) bool
@@ -14,34 +49,97 @@ type typeHandler interface {
 	lessOrEqual(left interface{}, right interface{}) bool
 }
 
-// ================ STRING  ====================}
+// ================ CUSTOM  ====================
+
+// customTypeHandler adapts a user TypeHandler to the comparisons used by comparers.
+type customTypeHandler struct {
+	handler TypeHandler
+}
+
+func (c customTypeHandler) convert(value string) (result interface{}, err error) {
+	result, err = c.handler.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %q: %w", value, err)
+	}
+	return result, nil
+}
+
+func (c customTypeHandler) equal(left interface{}, right interface{}) bool {
+	return c.handler.Compare(left, right) == 0
+}
+
+func (c customTypeHandler) greater(left interface{}, right interface{}) bool {
+	return c.handler.Compare(left, right) > 0
+}
+
+func (c customTypeHandler) less(left interface{}, right interface{}) bool {
+	return c.handler.Compare(left, right) < 0
+}
+
+func (c customTypeHandler) greaterOrEqual(left interface{}, right interface{}) bool {
+	return c.handler.Compare(left, right) >= 0
+}
+
+func (c customTypeHandler) lessOrEqual(left interface{}, right interface{}) bool {
+	return c.handler.Compare(left, right) <= 0
+}
+
+// underlyingValue converts a value of a named type with a built-in underlying kind,
+// such as `type Status string`, to the built-in type handled by the comparers.
+func underlyingValue(value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Bool:
+		return v.Bool()
+	}
+	return value
+}
+
+// ================ STRING  ====================
 
 type stringTypeHandler struct {
 	wildcard wildcard
+	matching StringMatching
 }
 
-func (stringTypeHandler) convert(value string) (result interface{}, err error) {
-	return value, nil
+func newStringTypeHandler(atomic *atomicValue) stringTypeHandler {
+	matching := atomic.comparers.stringMatching()
+	if matching == 0 {
+		return stringTypeHandler{wildcard: atomic.wildcard}
+	}
+	return stringTypeHandler{wildcard: atomic.wildcard.mapParts(matching.apply), matching: matching}
+}
+
+func (s stringTypeHandler) convert(value string) (result interface{}, err error) {
+	return s.matching.apply(value), nil
 }
 
 func (s stringTypeHandler) equal(left interface{}, right interface{}) bool {
-	return s.wildcard.Match(left.(string))
+	return s.wildcard.Match(s.matching.apply(left.(string)))
 }
 
 func (s stringTypeHandler) greater(left interface{}, right interface{}) bool {
-	return left.(string) > right.(string)
+	return s.matching.apply(left.(string)) > right.(string)
 }
 
 func (s stringTypeHandler) less(left interface{}, right interface{}) bool {
-	return left.(string) < right.(string)
+	return s.matching.apply(left.(string)) < right.(string)
 }
 
 func (s stringTypeHandler) greaterOrEqual(left interface{}, right interface{}) bool {
-	return left.(string) >= right.(string)
+	return s.matching.apply(left.(string)) >= right.(string)
 }
 
 func (s stringTypeHandler) lessOrEqual(left interface{}, right interface{}) bool {
-	return left.(string) <= right.(string)
+	return s.matching.apply(left.(string)) <= right.(string)
 }
 
 // ================ INT64  ====================
@@ -102,35 +200,69 @@ func (i uint64TypeHandler) lessOrEqual(left interface{}, right interface{}) bool
 
 // ================ TIME  ====================
 
-type timeTypeHandler struct{}
+type timeTypeHandler struct {
+	clock func() time.Time
+}
+
+// timeQuery is a query value compared with times. Dates and date math anchored to a date
+// are resolved when the value is converted, date math relative to now on every comparison.
+type timeQuery struct {
+	start time.Time
+	end   time.Time
+	math  *dateMath
+}
+
+func (t timeTypeHandler) convert(value string) (result interface{}, err error) {
+	math, err := parseDateMath(value)
+	if err != nil {
+		return nil, err
+	}
 
-func (timeTypeHandler) convert(value string) (result interface{}, err error) {
-	return time.Parse(time.RFC3339, value)
+	if math.relative {
+		return timeQuery{math: math}, nil
+	}
+
+	start, end := math.interval(time.Time{})
+	return timeQuery{start: start, end: end}, nil
+}
+
+// interval returns the first and the last instant of the query value.
+func (t timeTypeHandler) interval(value interface{}) (time.Time, time.Time) {
+	query := value.(timeQuery)
+	if query.math == nil {
+		return query.start, query.end
+	}
+
+	if t.clock == nil {
+		return query.math.interval(time.Now())
+	}
+	return query.math.interval(t.clock())
 }
 
 func (t timeTypeHandler) equal(left interface{}, right interface{}) bool {
-	return left.(time.Time).Equal(right.(time.Time))
+	start, end := t.interval(right)
+	l := left.(time.Time)
+	return !l.Before(start) && !l.After(end)
 }
 
 func (t timeTypeHandler) greater(left interface{}, right interface{}) bool {
-	return left.(time.Time).After(right.(time.Time))
+	_, end := t.interval(right)
+	return left.(time.Time).After(end)
 }
 
 func (t timeTypeHandler) less(left interface{}, right interface{}) bool {
-	return left.(time.Time).Before(right.(time.Time))
+	start, _ := t.interval(right)
+	return left.(time.Time).Before(start)
 }
 
 func (t timeTypeHandler) greaterOrEqual(left interface{}, right interface{}) bool {
-	l := left.(time.Time)
-	r := right.(time.Time)
-	return l.After(r) || l.Equal(r)
+	start, _ := t.interval(right)
+	return !left.(time.Time).Before(start)
 }
 
 func (t timeTypeHandler) lessOrEqual(left interface{}, right interface{}) bool {
-	l := left.(time.Time)
-	r := right.(time.Time)
-
-	return l.Before(r) || l.Equal(r)
+	_, end := t.interval(right)
+	return !left.(time.Time).After(end)
 }
 
 // ================ BOOL  ====================
@@ -220,3 +352,33 @@ func (d durationTypeHandler) greaterOrEqual(left interface{}, right interface{})
 func (d durationTypeHandler) lessOrEqual(left interface{}, right interface{}) bool {
 	return left.(time.Duration).Nanoseconds() <= right.(time.Duration).Nanoseconds()
 }
+
+// ================ NUMBER PATTERN ====================
+
+// numberPatternTypeHandler compares wildcard patterns such as `3*` with numeric properties.
+// Numbers are not matched as text, so patterns never match them.
+type numberPatternTypeHandler struct{}
+
+func (numberPatternTypeHandler) convert(value string) (result interface{}, err error) {
+	return value, nil
+}
+
+func (numberPatternTypeHandler) equal(left interface{}, right interface{}) bool {
+	return false
+}
+
+func (numberPatternTypeHandler) greater(left interface{}, right interface{}) bool {
+	return false
+}
+
+func (numberPatternTypeHandler) less(left interface{}, right interface{}) bool {
+	return false
+}
+
+func (numberPatternTypeHandler) greaterOrEqual(left interface{}, right interface{}) bool {
+	return false
+}
+
+func (numberPatternTypeHandler) lessOrEqual(left interface{}, right interface{}) bool {
+	return false
+}
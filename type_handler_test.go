@@ -0,0 +1,154 @@
+package gokql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testVersion struct {
+	Major, Minor, Patch int
+}
+
+func parseTestVersion(value string) (testVersion, error) {
+	var v testVersion
+	if _, err := fmt.Sscanf(value, "%d_%d_%d", &v.Major, &v.Minor, &v.Patch); err != nil {
+		return testVersion{}, err
+	}
+	return v, nil
+}
+
+func compareTestVersions(left testVersion, right testVersion) int {
+	for _, diff := range []int{left.Major - right.Major, left.Minor - right.Minor, left.Patch - right.Patch} {
+		if diff != 0 {
+			return diff
+		}
+	}
+	return 0
+}
+
+type testStatus string
+
+type testLevel int
+
+type testRatio float32
+
+type testFlag bool
+
+type testCurrency string
+
+// currencyHandler compares currency codes case-insensitively and does not use the built-in string handling.
+type currencyHandler struct{}
+
+func (currencyHandler) Parse(value string) (interface{}, error) {
+	return testCurrency(strings.ToUpper(value)), nil
+}
+
+func (currencyHandler) Compare(property interface{}, value interface{}) int {
+	return strings.Compare(strings.ToUpper(string(property.(testCurrency))), string(value.(testCurrency)))
+}
+
+type typedItem struct {
+	Version  testVersion
+	Versions []testVersion
+	Status   testStatus
+	Statuses []testStatus
+	Level    testLevel
+	Ratio    testRatio
+	Flag     testFlag
+	Currency testCurrency
+	Message  string
+}
+
+func TestTypeHandlers(t *testing.T) {
+	options := ParseOptions{
+		TypeHandlers: TypeHandlers{
+			reflect.TypeOf(testVersion{}):    NewTypeHandler(parseTestVersion, compareTestVersions),
+			reflect.TypeOf(testCurrency("")): currencyHandler{},
+		},
+	}
+	item := typedItem{
+		Version:  testVersion{1, 2, 3},
+		Versions: []testVersion{{1, 0, 0}, {2, 1, 0}},
+		Currency: "eur",
+	}
+
+	testMatch := func(query string, expected bool) {
+		t.Helper()
+		expr, err := ParseWithOptions(query, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := expr.Match(NewReflectEvaluator(item))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != expected {
+			t.Errorf("Unexpected match result: %v for expression %s", res, query)
+		}
+
+		match, err := CompileFor[typedItem](expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res, err := match(&item); err != nil || res != expected {
+			t.Errorf("Unexpected compiled match result: %v, %v for expression %s", res, err, query)
+		}
+	}
+
+	testMatch("Version:1_2_3", true)
+	testMatch("Version:1_2_4", false)
+	testMatch("Version>1_10_0", false)
+	testMatch("Version<1_10_0", true)
+	testMatch("Version>=(2_0_0 or 1_2_3)", true)
+	testMatch("Versions:2_1_0", true)
+	testMatch("Versions>2_1_0", false)
+	testMatch("Currency:EUR", true)
+	testMatch("Currency:(usd or gbp)", false)
+	testMatch("1_2_3", true)
+
+	expr, err := ParseWithOptions("Version:1.2", options)
+	if err == nil {
+		_, err = expr.Match(NewReflectEvaluator(item))
+	}
+	if err == nil {
+		t.Error("Expected error for a value which cannot be parsed by the type handler")
+	}
+
+	expr = mustParse(t, "Version:1_2_3")
+	if _, err := expr.Match(NewReflectEvaluator(item)); err == nil || !strings.Contains(err.Error(), "unsupported property type gokql.testVersion") {
+		t.Errorf("Expected unsupported type error without a handler, got %v", err)
+	}
+}
+
+func TestNamedBuiltinKinds(t *testing.T) {
+	item := typedItem{
+		Status:   "active",
+		Statuses: []testStatus{"new", "archived"},
+		Level:    3,
+		Ratio:    0.5,
+		Flag:     true,
+	}
+
+	testExpr(t, "Status:active", NewReflectEvaluator(item), true)
+	testExpr(t, "Status:act*", NewReflectEvaluator(item), true)
+	testExpr(t, "Status:(new or deleted)", NewReflectEvaluator(item), false)
+	testExpr(t, "Statuses:arch*", NewReflectEvaluator(item), true)
+	testExpr(t, "Level>2", NewReflectEvaluator(item), true)
+	testExpr(t, "Level:(1 or 3)", NewReflectEvaluator(item), true)
+	testExpr(t, "Ratio<'0.75'", NewReflectEvaluator(item), true)
+	testExpr(t, "Flag:true", NewReflectEvaluator(item), true)
+	testExpr(t, "active", NewReflectEvaluator(item), true)
+
+	testExprMap(t, "status:active", map[string]interface{}{"status": testStatus("active")}, true)
+
+	match, err := CompileFor[typedItem](mustParse(t, "Status:active and Level>=3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := match(&item); err != nil || !res {
+		t.Errorf("Unexpected compiled match result: %v, %v", res, err)
+	}
+}
@@ -10,14 +10,32 @@ type wildcard struct {
 	lastStar  bool
 }
 
-func newWildcard(wildcards string) wildcard {
-	if len(wildcards) == 0 {
+// newWildcard creates a wildcard from the pattern form of a value, in which `*` matches
+// any sequence of characters and `\*` and `\\` stand for a literal star and backslash.
+func newWildcard(pattern string) wildcard {
+	if len(pattern) == 0 {
 		return wildcard{}
 	}
-	parts := strings.Split(wildcards, "*")
-	firstStar := wildcards[0] == '*'
-	lastStar := wildcards[len(wildcards)-1] == '*'
-	if firstStar && len(parts) > 0 {
+
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			i++
+			part.WriteByte(pattern[i])
+		case pattern[i] == '*':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(pattern[i])
+		}
+	}
+	parts = append(parts, part.String())
+
+	firstStar := pattern[0] == '*'
+	lastStar := len(parts) > 1 && parts[len(parts)-1] == ""
+	if firstStar {
 		parts = parts[1:]
 	}
 	if lastStar && len(parts) > 0 {
@@ -31,6 +49,71 @@ func newWildcard(wildcards string) wildcard {
 	}
 }
 
+// literalWildcard creates a wildcard matching the value exactly.
+func literalWildcard(value string) wildcard {
+	return wildcard{parts: []string{value}}
+}
+
+// mapParts returns the wildcard with the function applied to its literal parts.
+func (w wildcard) mapParts(mapping func(string) string) wildcard {
+	parts := make([]string, len(w.parts))
+	for i, part := range w.parts {
+		parts[i] = mapping(part)
+	}
+	return wildcard{parts: parts, firstStar: w.firstStar, lastStar: w.lastStar}
+}
+
+// format renders the wildcard with escaped literal parts separated by star.
+func (w wildcard) format(star string, escape func(string) string) string {
+	if w.matchesAll() {
+		return star
+	}
+
+	var builder strings.Builder
+	if w.firstStar {
+		builder.WriteString(star)
+	}
+	for i, part := range w.parts {
+		if i > 0 {
+			builder.WriteString(star)
+		}
+		builder.WriteString(escape(part))
+	}
+	if w.lastStar {
+		builder.WriteString(star)
+	}
+	return builder.String()
+}
+
+// isPattern reports whether the wildcard contains at least one star.
+func (w wildcard) isPattern() bool {
+	return w.firstStar || w.lastStar || len(w.parts) > 1
+}
+
+// stars returns the number of stars of the wildcard.
+func (w wildcard) stars() int {
+	if len(w.parts) == 0 {
+		if w.isPattern() {
+			return 1
+		}
+		return 0
+	}
+
+	stars := len(w.parts) - 1
+	if w.firstStar {
+		stars++
+	}
+	if w.lastStar {
+		stars++
+	}
+	return stars
+}
+
+// matchesAll reports whether the wildcard consists of stars only.
+func (w wildcard) matchesAll() bool {
+	return w.firstStar && w.lastStar && len(w.parts) == 0
+}
+
 func (w wildcard) Match(str string) bool {
 	lenStr := len(str)
 	if len(w.parts) == 0 {